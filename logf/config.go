@@ -18,9 +18,38 @@ import (
 	"io"
 )
 
+// Config configures a Logger.
+// Usage notes:
+//   - LevelNames names levels in this logger's output. It defaults to DefaultLevelNames, and a
+//     format's own LevelNames setting takes precedence.
+//   - AddCaller sets the SOURCE prop on each Log call to the Frame that called it, unless the call
+//     sets SOURCE itself. Calls through this module's wrappers, like logf.Log, MultiLogger, and
+//     logconfig.Tree, report their caller. It costs nothing when it's off.
+//   - CallerSkip skips more frames above the call, for helpers that wrap Log
+//   - AddStack sets the STACK prop to the goroutine's stack on Log calls at Stack.Level or more
+//     severe, unless the call sets STACK itself
 type Config struct {
 	MaxLevel     LogLevel
 	DefaultLevel LogLevel
 	Format       Formatter
 	Output       io.Writer
+	LevelNames   *LevelNames
+	AddCaller    bool
+	CallerSkip   int
+	AddStack     bool
+	Stack        StackOptions
+}
+
+// StackOptions configures the stacks added by Config.AddStack.
+// Usage notes:
+//   - Level is the least severe level that gets a stack. The zero value is Emergency, so set it,
+//     like to Critical.
+//   - MaxDepth caps the number of frames, and defaults to 32
+//   - Frames from logf and its wrappers, and from package runtime, are trimmed unless KeepLogf or
+//     KeepRuntime is set
+type StackOptions struct {
+	Level       LogLevel
+	MaxDepth    int
+	KeepLogf    bool
+	KeepRuntime bool
 }
@@ -0,0 +1,163 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logf
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ERROR is the prop that Err sets.
+const ERROR = string("error")
+
+// maxErrorDepth bounds how deep Err walks an error's causes, in case of cycles.
+const maxErrorDepth = 32
+
+// ErrorValue is the value of an error prop from Err: the error's message and type, and those of
+// its causes.
+// Usage notes:
+//   - Message is the error's Error string, and Type is its concrete type, like *fs.PathError
+//   - Causes are the errors it wraps: one for errors with Unwrap() error, or several for joined
+//     errors with Unwrap() []error, like errors.Join
+//   - Stack is the stack the error recorded, if it has one. Errors with a Callers() []uintptr
+//     method, or a StackTrace method returning program counters like github.com/pkg/errors, are
+//     supported. ErrStack records the caller's stack instead when no error in the chain has one.
+//
+// ErrorValue is an error itself, with the original error as its cause, so errors.Is and errors.As
+// work on the prop value. Text formats write the message, and structured formats write an object
+// with message, type, causes, and stack.
+type ErrorValue struct {
+	Message string
+	Type    string
+	Causes  []*ErrorValue
+	Stack   []Frame
+
+	err error
+}
+
+func (value *ErrorValue) Error() string {
+	return value.Message
+}
+
+func (value *ErrorValue) Unwrap() error {
+	return value.err
+}
+
+// HasStack reports whether value or any of its causes has a stack.
+func (value *ErrorValue) HasStack() bool {
+	if len(value.Stack) > 0 {
+		return true
+	}
+	for _, cause := range value.Causes {
+		if cause.HasStack() {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns an ERROR prop describing err. A nil err gives a nil value, as does a nil pointer of
+// an error type, whose Error method would usually panic.
+func Err(err error) Prop {
+	return NamedErr(ERROR, err)
+}
+
+// NamedErr returns a prop describing err, like Err, with the given name.
+func NamedErr(name string, err error) Prop {
+	if isNilError(err) {
+		return Prop{Name: name}
+	}
+	return Prop{Name: name, Value: NewErrorValue(err)}
+}
+
+// ErrStack returns an ERROR prop describing err, like Err. If no error in its chain recorded a
+// stack, the caller's stack is recorded instead.
+func ErrStack(err error) Prop {
+	if isNilError(err) {
+		return Prop{Name: ERROR}
+	}
+	value := NewErrorValue(err)
+	if !value.HasStack() {
+		value.Stack = callers(0)
+	}
+	return Prop{Name: ERROR, Value: value}
+}
+
+// NewErrorValue describes err and its causes. A nil err, or a nil pointer of an error type, gives
+// nil, and causes like that are left out.
+func NewErrorValue(err error) *ErrorValue {
+	if isNilError(err) {
+		return nil
+	}
+	return newErrorValue(err, 0)
+}
+
+// isNilError reports whether err is nil, or a nil pointer, like a nil *fs.PathError returned as an
+// error.
+func isNilError(err error) bool {
+	if err == nil {
+		return true
+	}
+	rv := reflect.ValueOf(err)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
+
+func newErrorValue(err error, depth int) *ErrorValue {
+	value := &ErrorValue{
+		Message: err.Error(),
+		Type:    fmt.Sprintf("%T", err),
+		Stack:   errorStack(err),
+		err:     err,
+	}
+	if depth >= maxErrorDepth {
+		return value
+	}
+	var causes []error
+	switch wrapped := err.(type) {
+	case interface{ Unwrap() error }:
+		causes = []error{wrapped.Unwrap()}
+	case interface{ Unwrap() []error }:
+		causes = wrapped.Unwrap()
+	}
+	for _, cause := range causes {
+		if !isNilError(cause) {
+			value.Causes = append(value.Causes, newErrorValue(cause, depth+1))
+		}
+	}
+	return value
+}
+
+// errorStack returns the stack recorded by err itself, not its causes.
+func errorStack(err error) []Frame {
+	if withCallers, ok := err.(interface{ Callers() []uintptr }); ok {
+		return framesOf(withCallers.Callers())
+	}
+	// github.com/pkg/errors and its forks return a named slice of uintptr-based frames, which
+	// can't be matched with an interface without importing them.
+	method := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return nil
+	}
+	out := method.Type().Out(0)
+	if out.Kind() != reflect.Slice || out.Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+	trace := method.Call(nil)[0]
+	pcs := make([]uintptr, trace.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(trace.Index(i).Uint())
+	}
+	return framesOf(pcs)
+}
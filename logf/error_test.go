@@ -0,0 +1,151 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logf_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/decentplatforms/appkit/logf"
+	"github.com/decentplatforms/appkit/logf/testhelp"
+)
+
+// callersError records its stack like errors from github.com/go-errors/errors.
+type callersError struct {
+	pcs []uintptr
+}
+
+func (err *callersError) Error() string { return "with callers" }
+
+func (err *callersError) Callers() []uintptr { return err.pcs }
+
+func newCallersError() error {
+	pcs := make([]uintptr, 8)
+	return &callersError{pcs: pcs[:runtime.Callers(1, pcs)]}
+}
+
+// pkgFrame and pkgStackTrace mirror the types used by github.com/pkg/errors.
+type pkgFrame uintptr
+type pkgStackTrace []pkgFrame
+
+type pkgError struct {
+	stack pkgStackTrace
+}
+
+func (err *pkgError) Error() string { return "pkg error" }
+
+func (err *pkgError) StackTrace() pkgStackTrace { return err.stack }
+
+func newPkgError() error {
+	pcs := make([]uintptr, 8)
+	n := runtime.Callers(1, pcs)
+	err := &pkgError{}
+	for _, pc := range pcs[:n] {
+		err.stack = append(err.stack, pkgFrame(pc))
+	}
+	return err
+}
+
+func TestErr(t *testing.T) {
+	pathErr := &fs.PathError{Op: "open", Path: "/nope", Err: fs.ErrNotExist}
+	err := fmt.Errorf("loading config: %w", errors.Join(pathErr, errors.New("also bad")))
+	prop := logf.Err(err)
+	value, ok := prop.Value.(*logf.ErrorValue)
+	if !ok || prop.Name != logf.ERROR {
+		t.Fatalf("wrong prop: %#v", prop)
+	}
+
+	res := testhelp.ResultsMap{
+		"message":      value.Message,
+		"type":         value.Type,
+		"joined_type":  value.Causes[0].Type,
+		"joined_count": fmt.Sprint(len(value.Causes[0].Causes)),
+		"path_type":    value.Causes[0].Causes[0].Type,
+		"path_cause":   value.Causes[0].Causes[0].Causes[0].Message,
+		"second":       value.Causes[0].Causes[1].Message,
+		"is":           fmt.Sprint(errors.Is(value, fs.ErrNotExist)),
+		"text":         fmt.Sprint(value),
+	}
+	wanted := testhelp.ResultsMap{
+		"message":      "loading config: open /nope: file does not exist\nalso bad",
+		"type":         "*fmt.wrapError",
+		"joined_type":  "*errors.joinError",
+		"joined_count": "2",
+		"path_type":    "*fs.PathError",
+		"path_cause":   "file does not exist",
+		"second":       "also bad",
+		"is":           "true",
+		"text":         "loading config: open /nope: file does not exist\nalso bad",
+	}
+	if err := testhelp.ValidateResults(res, wanted); err != nil {
+		t.Fatal(err)
+	}
+	if value.HasStack() {
+		t.Error("errors without stacks got one")
+	}
+	if logf.Err(nil).Value != nil {
+		t.Error("Err(nil) has a value")
+	}
+}
+
+func TestErrTypedNil(t *testing.T) {
+	var pathErr *fs.PathError
+	var err error = pathErr
+	for name, prop := range map[string]logf.Prop{
+		"err":       logf.Err(err),
+		"named":     logf.NamedErr("cause", err),
+		"any":       logf.Any("cause", err),
+		"err_stack": logf.ErrStack(err),
+	} {
+		if prop.Value != nil {
+			t.Errorf("%s: typed nil error has a value: %#v", name, prop.Value)
+		}
+	}
+	value := logf.NewErrorValue(fmt.Errorf("wrapped: %w", err))
+	if value == nil || len(value.Causes) != 0 {
+		t.Errorf("typed nil cause wasn't left out: %#v", value)
+	}
+}
+
+func TestErrStack(t *testing.T) {
+	stacks := map[string]*logf.ErrorValue{
+		"callers": logf.NewErrorValue(fmt.Errorf("wrapped: %w", newCallersError())).Causes[0],
+		"pkg":     logf.NewErrorValue(newPkgError()),
+		"caller":  logf.ErrStack(errors.New("plain")).Value.(*logf.ErrorValue),
+	}
+	wanted := map[string]string{
+		"callers": "logf_test.newCallersError",
+		"pkg":     "logf_test.newPkgError",
+		"caller":  "logf_test.TestErrStack",
+	}
+	for name, value := range stacks {
+		if len(value.Stack) == 0 {
+			t.Errorf("%s: no stack", name)
+			continue
+		}
+		if fn := value.Stack[0].Function; !strings.HasSuffix(fn, wanted[name]) {
+			t.Errorf("%s: stack starts at %s, wanted %s", name, fn, wanted[name])
+		}
+	}
+
+	// ErrStack keeps a stack the error already has.
+	if value := logf.ErrStack(newCallersError()).Value.(*logf.ErrorValue); !strings.HasSuffix(value.Stack[0].Function, "newCallersError") {
+		t.Errorf("ErrStack replaced the error's stack with %s", value.Stack[0].Function)
+	}
+}
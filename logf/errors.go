@@ -20,4 +20,8 @@ var NilOutputError = errors.New("loggers must have a non-nil output")
 var NilFormatError = errors.New("loggers must have a format")
 var MultiConfigError = errors.New("can't configure MultiLogger; configure subloggers instead")
 
+var UnknownLevelError = errors.New("unknown log level")
+
+// NoActiveLoggerError isn't returned anymore, since the package-level functions fall back to a
+// default logger.
 var NoActiveLoggerError = errors.New("no active logger")
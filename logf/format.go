@@ -16,9 +16,11 @@ package logf
 
 import (
 	"fmt"
+	"reflect"
 	"slices"
 	"strings"
 	"sync"
+	"time"
 )
 
 type Prop struct {
@@ -46,32 +48,33 @@ func Stringer[T fmt.Stringer](name string, value T) Prop {
 	}
 }
 
-// Int returns a prop whose value is an int.
-// T may be any type that has an underlying type of int, int64, uint, or uint64.
+// Int returns a prop whose value is an int64.
+// T may be any type that has an underlying type of int, int8, int16, int32, or int64.
 // This converts the value to an int64.
-func Int[T ~int | ~int64](name string, value T) Prop {
+func Int[T ~int | ~int8 | ~int16 | ~int32 | ~int64](name string, value T) Prop {
 	return Prop{
 		Name:  name,
-		Value: value,
+		Value: int64(value),
 	}
 }
 
-// UInt returns a prop whose value is an unsigned int.
-// T may be any type that has an underlying type of uint, or uint64.
+// UInt returns a prop whose value is a uint64.
+// T may be any type that has an underlying type of uint, uint8, uint16, uint32, uint64, or uintptr.
 // This converts the value to a uint64.
-func UInt[T ~uint | ~uint64](name string, value T) Prop {
+func UInt[T ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr](name string, value T) Prop {
 	return Prop{
 		Name:  name,
-		Value: value,
+		Value: uint64(value),
 	}
 }
 
-// Float returns a prop whose value is a float.
-// T may be any type that has an underlying type of float64.
-func Float[T ~float64](name string, value T) Prop {
+// Float returns a prop whose value is a float64.
+// T may be any type that has an underlying type of float32 or float64.
+// This converts the value to a float64.
+func Float[T ~float32 | ~float64](name string, value T) Prop {
 	return Prop{
 		Name:  name,
-		Value: value,
+		Value: float64(value),
 	}
 }
 
@@ -84,6 +87,137 @@ func Bool[T ~bool](name string, value T) Prop {
 	}
 }
 
+// Time returns a prop whose value is a time.Time.
+// Formats write it as an RFC 3339 string with nanoseconds, in UTC.
+func Time(name string, value time.Time) Prop {
+	return Prop{
+		Name:  name,
+		Value: value,
+	}
+}
+
+// Duration returns a prop whose value is a time.Duration.
+// Formats write it as its String form, like 1.5s.
+func Duration(name string, value time.Duration) Prop {
+	return Prop{
+		Name:  name,
+		Value: value,
+	}
+}
+
+// Bytes returns a prop whose value is a []byte.
+// Text and JSON formats write it as base64, and binary formats as bytes.
+func Bytes(name string, value []byte) Prop {
+	return Prop{
+		Name:  name,
+		Value: value,
+	}
+}
+
+// Strings returns a prop whose value is a []string.
+// T may be any type that has an underlying type of string. The values are copied.
+func Strings[T ~string](name string, values []T) Prop {
+	strs := make([]string, len(values))
+	for i, value := range values {
+		strs[i] = string(value)
+	}
+	return Prop{
+		Name:  name,
+		Value: strs,
+	}
+}
+
+// Ints returns a prop whose value is an []int64.
+// T may be any type that Int accepts. The values are converted to int64s.
+func Ints[T ~int | ~int8 | ~int16 | ~int32 | ~int64](name string, values []T) Prop {
+	ints := make([]int64, len(values))
+	for i, value := range values {
+		ints[i] = int64(value)
+	}
+	return Prop{
+		Name:  name,
+		Value: ints,
+	}
+}
+
+// PropMarshaler is implemented by types that log as an object with named fields.
+// MarshalProps returns the fields in order. Their values may be any prop value, including other
+// objects.
+type PropMarshaler interface {
+	MarshalProps() []Prop
+}
+
+// Object returns a prop whose value is the []Prop from value.MarshalProps.
+// Structured formats write it as a nested object, and text formats as compact JSON.
+// If value is nil, including a nil pointer, the prop's value is nil and MarshalProps isn't called.
+func Object(name string, value PropMarshaler) Prop {
+	if value == nil {
+		return Prop{Name: name}
+	}
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return Prop{Name: name}
+	}
+	return Prop{
+		Name:  name,
+		Value: value.MarshalProps(),
+	}
+}
+
+// PropGroup is the value of a Group prop: props that belong together under the group's name.
+type PropGroup []Prop
+
+// Group returns a prop whose value is a PropGroup of props. The props are copied.
+// Usage notes:
+//   - Structured formats write a group as a nested object, and text formats like logfmt write
+//     each prop with a dotted name, like http.method
+//   - Syslog5424Format writes top-level groups as SD-ELEMENTs, named by the group
+//   - Props.Get and PropsView.Get find props in groups by dotted path, like "http.method"
+//   - Use Namespace to put all of a logger's props in a group
+func Group(name string, props ...Prop) Prop {
+	return Prop{
+		Name:  name,
+		Value: PropGroup(append([]Prop{}, props...)),
+	}
+}
+
+// Any returns a prop for value, using the constructor for its type:
+//   - Built-in integer and float types are converted like Int, UInt, and Float
+//   - Errors are described like Err
+//   - PropMarshalers are objects, like Object
+//
+// Other values, including named types like LogLevel, are kept as they are.
+func Any(name string, value any) Prop {
+	switch v := value.(type) {
+	case int:
+		return Int(name, v)
+	case int8:
+		return Int(name, v)
+	case int16:
+		return Int(name, v)
+	case int32:
+		return Int(name, v)
+	case uint:
+		return UInt(name, v)
+	case uint8:
+		return UInt(name, v)
+	case uint16:
+		return UInt(name, v)
+	case uint32:
+		return UInt(name, v)
+	case uintptr:
+		return UInt(name, v)
+	case float32:
+		return Float(name, v)
+	case *ErrorValue:
+		return Prop{Name: name, Value: v}
+	case error:
+		return NamedErr(name, v)
+	case PropMarshaler:
+		return Object(name, v)
+	}
+	return Prop{Name: name, Value: value}
+}
+
 // Props are an ordered collection of log properties.
 type Props struct {
 	props []Prop
@@ -107,7 +241,7 @@ func getProps(ct int) *Props {
 	if diff > 0 {
 		newProps.props = append(newProps.props, make([]Prop, diff)...)
 	} else if diff < 0 {
-		newProps.props = newProps.props[:-diff]
+		newProps.props = newProps.props[:ct]
 	}
 	return newProps
 }
@@ -123,16 +257,64 @@ func NewProps(props ...Prop) *Props {
 }
 
 // Get returns a named log property in the calling props.
+// name may be a dotted path into groups and objects, like "http.method"; a prop whose name
+// contains the dots itself is found first.
 // If there's no matching property, returns nil instead.
 func (props *Props) Get(name string) any {
-	if idx, ok := props.hash[name]; ok && idx < len(props.props) {
+	return props.get(name, func(string) bool { return true })
+}
+
+// get returns the prop at the dotted path name, if visible allows its top-level prop.
+func (props *Props) get(name string, visible func(string) bool) any {
+	if idx, ok := props.hash[name]; ok && idx < len(props.props) && visible(name) {
 		return props.props[idx].Value
 	}
+	for i := 0; i < len(name); i++ {
+		if name[i] != '.' {
+			continue
+		}
+		if idx, ok := props.hash[name[:i]]; ok && idx < len(props.props) && visible(name[:i]) {
+			if value, ok := memberAt(props.props[idx].Value, name[i+1:]); ok {
+				return value
+			}
+		}
+	}
 	return nil
 }
 
+// memberAt returns the member at the dotted path in a PropGroup or an Object's []Prop.
+func memberAt(value any, path string) (any, bool) {
+	var members []Prop
+	switch v := value.(type) {
+	case PropGroup:
+		members = v
+	case []Prop:
+		members = v
+	default:
+		return nil, false
+	}
+	for _, member := range members {
+		if member.Name == path {
+			return member.Value, true
+		}
+	}
+	for _, member := range members {
+		if rest, ok := strings.CutPrefix(path, member.Name+"."); ok {
+			if value, ok := memberAt(member.Value, rest); ok {
+				return value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// PropGetter is implemented by *Props and PropsView, so the GetX helpers work with either.
+type PropGetter interface {
+	Get(name string) any
+}
+
 // GetString gets a named string prop with default value def.
-func GetString[T ~string](props *Props, name string, def T) T {
+func GetString[T ~string](props PropGetter, name string, def T) T {
 	if v, ok := props.Get(name).(T); ok {
 		return v
 	}
@@ -140,31 +322,47 @@ func GetString[T ~string](props *Props, name string, def T) T {
 }
 
 // GetInt gets a named int prop with default value def.
-func GetInt[T ~int | ~int64](props *Props, name string, def T) T {
-	if v, ok := props.Get(name).(T); ok {
+// It accepts values of type T, and the int64s from Int that fit in T.
+func GetInt[T ~int | ~int8 | ~int16 | ~int32 | ~int64](props PropGetter, name string, def T) T {
+	switch v := props.Get(name).(type) {
+	case T:
 		return v
+	case int64:
+		if int64(T(v)) == v {
+			return T(v)
+		}
 	}
 	return def
 }
 
 // GetUInt gets a named uint prop with default value def.
-func GetUInt[T ~uint | ~uint64](props *Props, name string, def T) T {
-	if v, ok := props.Get(name).(T); ok {
+// It accepts values of type T, and the uint64s from UInt that fit in T.
+func GetUInt[T ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr](props PropGetter, name string, def T) T {
+	switch v := props.Get(name).(type) {
+	case T:
 		return v
+	case uint64:
+		if uint64(T(v)) == v {
+			return T(v)
+		}
 	}
 	return def
 }
 
 // GetFloat gets a named float prop with default value def.
-func GetFloat[T ~float64](props *Props, name string, def T) T {
-	if v, ok := props.Get(name).(T); ok {
+// It accepts values of type T, and the float64s from Float.
+func GetFloat[T ~float32 | ~float64](props PropGetter, name string, def T) T {
+	switch v := props.Get(name).(type) {
+	case T:
 		return v
+	case float64:
+		return T(v)
 	}
 	return def
 }
 
 // GetBool gets a named bool prop with default value def.
-func GetBool[T ~bool](props *Props, name string, def T) T {
+func GetBool[T ~bool](props PropGetter, name string, def T) T {
 	if v, ok := props.Get(name).(T); ok {
 		return v
 	}
@@ -217,6 +415,109 @@ func (props *Props) Return() {
 	propsPool.Put(props)
 }
 
+// View returns a read-only view of props.
+func (props *Props) View() PropsView {
+	return PropsView{props: props}
+}
+
+// PropsView is a read-only view of Props.
+// Formatters receive a PropsView instead of *Props, so formatting a message never changes the props
+// seen by other formatters (for example, the subloggers of a MultiLogger).
+// A formatter that consumes some props itself, like syslog headers, uses Without to keep them
+// out of its output.
+type PropsView struct {
+	props *Props
+	omit  []string
+	names *LevelNames
+	// raw records the output of formatters wrapped with Raw during one formatView call. It
+	// belongs to the call, not the props, so props formatted concurrently don't share it.
+	raw *rawOutput
+}
+
+// rawOutput is the last output of a Raw formatter.
+type rawOutput struct {
+	out string
+	set bool
+}
+
+// LevelName returns the name of level from the logger's Config.LevelNames, or from the default
+// LevelNames. Formatters use it to name levels.
+func (view PropsView) LevelName(level LogLevel) string {
+	return view.names.Name(level)
+}
+
+// LevelNames returns the logger's Config.LevelNames, or the default LevelNames.
+func (view PropsView) LevelNames() *LevelNames {
+	if view.names == nil {
+		return DefaultLevelNames()
+	}
+	return view.names
+}
+
+func (view PropsView) visible(name string) bool {
+	return !slices.Contains(view.omit, name)
+}
+
+// Get returns a named log property in the view, or a member of a group by dotted path, like
+// Props.Get.
+// If there's no matching property, or it's been omitted, returns nil instead.
+func (view PropsView) Get(name string) any {
+	if view.props == nil {
+		return nil
+	}
+	return view.props.get(name, view.visible)
+}
+
+// Len returns the number of props in the view.
+func (view PropsView) Len() int {
+	if view.props == nil {
+		return 0
+	}
+	ct := 0
+	for _, prop := range view.props.props {
+		if view.visible(prop.Name) {
+			ct++
+		}
+	}
+	return ct
+}
+
+// Slice returns the props in the view, in order.
+// The returned slice is a copy, so it's safe to modify.
+func (view PropsView) Slice() []Prop {
+	if view.props == nil {
+		return nil
+	}
+	props := make([]Prop, 0, len(view.props.props))
+	for _, prop := range view.props.props {
+		if view.visible(prop.Name) {
+			props = append(props, prop)
+		}
+	}
+	return props
+}
+
+// Map returns a map of key-value pairs in the view.
+func (view PropsView) Map() map[string]any {
+	if view.props == nil {
+		return map[string]any{}
+	}
+	propsMap := view.props.Map()
+	for _, name := range view.omit {
+		delete(propsMap, name)
+	}
+	return propsMap
+}
+
+// Without returns a view that omits the named props.
+// The underlying Props are unchanged.
+func (view PropsView) Without(propnames ...string) PropsView {
+	omit := make([]string, 0, len(view.omit)+len(propnames))
+	omit = append(omit, view.omit...)
+	view.omit = append(omit, propnames...)
+	return view
+}
+
 // Formatter defines how Logger.Log and logger.Write output messages.
 // When using Logger.Log, the included props will be passed through, but they are not
 // included when using Logger as an io.Writer.
@@ -224,15 +525,45 @@ func (props *Props) Return() {
 // Do not call formatters directly. Use Formatter.FormatAndNormalize; it normalizes whitespace/newlines
 // for you so you don't have to worry about it in your formatter.
 //
+// Formatters receive a read-only PropsView, since the same props may be formatted more than once.
+//
 // By default, log uses the RFC5424 syslog format.
-type Formatter func(level LogLevel, msg string, props *Props) string
+type Formatter func(level LogLevel, msg string, props PropsView) string
 
 func (formatter Formatter) FormatAndNormalize(level LogLevel, msg string, props *Props) string {
-	out := formatter(level, msg, props)
+	return formatter.formatView(level, msg, props.View())
+}
+
+func (formatter Formatter) formatView(level LogLevel, msg string, view PropsView) string {
+	raw := &rawOutput{}
+	view.raw = raw
+	out := formatter(level, msg, view)
+	if raw.set && out == raw.out {
+		return out
+	}
 	out = NormalizeWhitespace(out)
 	return out
 }
 
+// Raw returns a formatter whose output is written exactly as formatter returns it, without
+// NormalizeWhitespace. Use it for binary formats, where trimming or adding newlines would corrupt
+// the output.
+// Usage notes:
+//   - The output itself isn't marked, so calling the formatter directly returns formatter's output
+//     unchanged
+//   - A formatter that returns a Raw formatter's output as is, like a wrapper that picks one of
+//     several formats, is raw too. A formatter that changes the output, like one that adds a
+//     prefix, is normalized as usual.
+func Raw(formatter Formatter) Formatter {
+	return func(level LogLevel, msg string, props PropsView) string {
+		out := formatter(level, msg, props)
+		if props.raw != nil {
+			props.raw.out, props.raw.set = out, true
+		}
+		return out
+	}
+}
+
 // ===== UTILITIES =====
 
 func NormalizeWhitespace(msg string) string {
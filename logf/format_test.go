@@ -0,0 +1,242 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logf_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+	"github.com/decentplatforms/appkit/logf/testhelp"
+)
+
+type testPoint struct {
+	X, Y int
+}
+
+func (point testPoint) MarshalProps() []logf.Prop {
+	return []logf.Prop{logf.Int("x", point.X), logf.Int("y", point.Y)}
+}
+
+func TestPropConstructors(t *testing.T) {
+	type port uint16
+	type ratio float32
+	props := []logf.Prop{
+		logf.Int("int32", int32(-5)),
+		logf.UInt("port", port(8080)),
+		logf.Float("ratio", ratio(0.5)),
+		logf.Time("time", time.Unix(0, 0)),
+		logf.Duration("duration", time.Second),
+		logf.Bytes("bytes", []byte{1}),
+		logf.Strings("strings", []string{"a"}),
+		logf.Ints("ints", []int8{1, 2}),
+		logf.Object("object", testPoint{X: 1, Y: 2}),
+		logf.Object("nil_object", (*testPoint)(nil)),
+		logf.Any("nil_any", (*testPoint)(nil)),
+		logf.Any("any_int", 7),
+		logf.Any("any_uint", uint8(7)),
+		logf.Any("any_float", float32(1)),
+		logf.Any("any_err", errors.New("bad")),
+		logf.Any("any_object", testPoint{}),
+		logf.Any("any_level", logf.Warning),
+	}
+	res := testhelp.ResultsMap{}
+	for _, prop := range props {
+		res[prop.Name] = fmt.Sprintf("%T", prop.Value)
+	}
+	wanted := testhelp.ResultsMap{
+		"int32":      "int64",
+		"port":       "uint64",
+		"ratio":      "float64",
+		"time":       "time.Time",
+		"duration":   "time.Duration",
+		"bytes":      "[]uint8",
+		"strings":    "[]string",
+		"ints":       "[]int64",
+		"object":     "[]logf.Prop",
+		"nil_object": "<nil>",
+		"nil_any":    "<nil>",
+		"any_int":    "int64",
+		"any_uint":   "uint64",
+		"any_float":  "float64",
+		"any_err":    "*logf.ErrorValue",
+		"any_object": "[]logf.Prop",
+		"any_level":  "logf.LogLevel",
+	}
+	if err := testhelp.ValidateResults(res, wanted); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPooledProps(t *testing.T) {
+	// Returned props are reused, so a smaller set must not keep the old set's extra props.
+	for i := 0; i < 10; i++ {
+		big := logf.NewProps(logf.Int("a", 1), logf.Int("b", 2), logf.Int("c", 3), logf.Int("d", 4), logf.Int("e", 5))
+		big.Return()
+		small := logf.NewProps(logf.Int("x", 1), logf.Int("y", 2))
+		if names := fmt.Sprint(small.Slice()); len(small.Slice()) != 2 || small.Get("c") != nil {
+			t.Fatalf("reused props kept stale entries: %s", names)
+		}
+		small.Return()
+	}
+}
+
+func TestPropGetters(t *testing.T) {
+	props := logf.NewProps(
+		logf.Int("small", 5),
+		logf.Int("big", 300),
+		logf.UInt("port", uint16(8080)),
+		logf.Float("ratio", 0.5),
+		logf.Prop{Name: "raw_int", Value: 9},
+	)
+	defer props.Return()
+	res := testhelp.ResultsMap{
+		"small":      fmt.Sprint(logf.GetInt(props, "small", 0)),
+		"as_int8":    fmt.Sprint(logf.GetInt(props, "small", int8(-1))),
+		"overflow":   fmt.Sprint(logf.GetInt(props, "big", int8(-1))),
+		"port":       fmt.Sprint(logf.GetUInt(props, "port", uint16(0))),
+		"ratio":      fmt.Sprint(logf.GetFloat(props, "ratio", float32(0))),
+		"raw_int":    fmt.Sprint(logf.GetInt(props, "raw_int", 0)),
+		"wrong_kind": fmt.Sprint(logf.GetUInt(props, "small", uint(1))),
+	}
+	wanted := testhelp.ResultsMap{
+		"small":      "5",
+		"as_int8":    "5",
+		"overflow":   "-1",
+		"port":       "8080",
+		"ratio":      "0.5",
+		"raw_int":    "9",
+		"wrong_kind": "1",
+	}
+	if err := testhelp.ValidateResults(res, wanted); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGroupPaths(t *testing.T) {
+	props := logf.NewProps(
+		logf.String("id", "a1"),
+		logf.Group("http", logf.String("method", "GET"), logf.Group("client", logf.String("ip", "10.0.0.1"))),
+		logf.Object("point", testPoint{X: 1, Y: 2}),
+		logf.String("dotted.name", "literal"),
+	)
+	defer props.Return()
+	view := props.View().Without("point")
+	res := testhelp.ResultsMap{
+		"top":       fmt.Sprint(props.Get("id")),
+		"member":    fmt.Sprint(props.Get("http.method")),
+		"nested":    fmt.Sprint(props.Get("http.client.ip")),
+		"object":    fmt.Sprint(props.Get("point.y")),
+		"literal":   fmt.Sprint(props.Get("dotted.name")),
+		"missing":   fmt.Sprint(props.Get("http.status")),
+		"not_group": fmt.Sprint(props.Get("id.x")),
+		"view":      fmt.Sprint(view.Get("http.client.ip")),
+		"omitted":   fmt.Sprint(view.Get("point.y")),
+	}
+	wanted := testhelp.ResultsMap{
+		"top":       "a1",
+		"member":    "GET",
+		"nested":    "10.0.0.1",
+		"object":    "2",
+		"literal":   "literal",
+		"missing":   "<nil>",
+		"not_group": "<nil>",
+		"view":      "10.0.0.1",
+		"omitted":   "<nil>",
+	}
+	if err := testhelp.ValidateResults(res, wanted); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	var out strings.Builder
+	format := func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		return fmt.Sprintf("%s %d %v", msg, props.Len(), props.Get("http.client.ip"))
+	}
+	log, _ := logf.NewLogger(logf.Config{MaxLevel: logf.Debug, Format: format, Output: &out})
+	http := logf.Namespace(log, "http")
+	client := logf.Namespace(http, "client")
+	http.Log(logf.Informational, "http", logf.Group("client", logf.String("ip", "10.0.0.1")))
+	client.Log(logf.Informational, "client", logf.String("ip", "10.0.0.2"))
+	client.Log(logf.Informational, "empty")
+	if out.String() != "http 1 10.0.0.1\nclient 1 10.0.0.2\nempty 0 <nil>\n" {
+		t.Errorf("wrong namespaced output: %q", out.String())
+	}
+
+	out.Reset()
+	reserved := func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		return fmt.Sprintf("%s %d %v %v", msg, props.Len(), props.Get(logf.ERROR), props.Get("http.client.ip"))
+	}
+	log.Configure(logf.Config{MaxLevel: logf.Debug, Format: reserved, Output: &out})
+	client.Log(logf.Error, "failed", logf.Err(errors.New("timeout")), logf.String("ip", "10.0.0.3"))
+	client.Log(logf.Error, "only", logf.Err(errors.New("refused")))
+	if out.String() != "failed 2 timeout 10.0.0.3\nonly 1 refused <nil>\n" {
+		t.Errorf("reserved props should stay at the top level: %q", out.String())
+	}
+}
+
+func TestRaw(t *testing.T) {
+	binary := func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		return "\n\x00" + msg + " "
+	}
+	raw := logf.Raw(binary)
+	wrapped := logf.Formatter(func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		return "<" + raw(level, msg, props) + ">"
+	})
+	props := logf.NewProps()
+	defer props.Return()
+	res := testhelp.ResultsMap{
+		"formatted": raw.FormatAndNormalize(logf.Informational, "msg", props),
+		"direct":    raw(logf.Informational, "msg", props.View()),
+		"wrapped":   wrapped.FormatAndNormalize(logf.Informational, "msg", props),
+		"plain":     logf.Formatter(binary).FormatAndNormalize(logf.Informational, "msg", props),
+		"nil_props": raw.FormatAndNormalize(logf.Informational, "msg", nil),
+	}
+	wanted := testhelp.ResultsMap{
+		"formatted": "\n\x00msg ",
+		"direct":    "\n\x00msg ",
+		"wrapped":   "<\n\x00msg >\n",
+		"plain":     "\x00msg\n",
+		"nil_props": "\n\x00msg ",
+	}
+	if err := testhelp.ValidateResults(res, wanted); err != nil {
+		t.Error(err)
+	}
+
+	// Formatting shared props with a raw and a normal formatter at once doesn't mix them up.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if out := raw.FormatAndNormalize(logf.Informational, "msg", props); out != "\n\x00msg " {
+					t.Errorf("raw output was normalized: %q", out)
+					return
+				}
+				if out := logf.Formatter(binary).FormatAndNormalize(logf.Informational, "msg", props); out != "\x00msg\n" {
+					t.Errorf("plain output wasn't normalized: %q", out)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,232 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+var BinarySyntaxError = errors.New("invalid binary log record")
+var BinaryFrameTooLargeError = errors.New("binary log frame is too large")
+
+// MaxBinaryFrameSize is the largest record a RecordDecoder reads.
+const MaxBinaryFrameSize = 64 << 20
+
+// maxBinaryDepth limits nesting when decoding, so corrupt input can't exhaust the stack.
+const maxBinaryDepth = 128
+
+// binaryFormat returns a formatter that encodes the JSONFormat record with encode, framed with a
+// 4 byte big-endian length.
+func binaryFormat(conf JSONConfig, encode func([]byte, any) []byte) logf.Formatter {
+	conf = conf.withDefaults()
+	return logf.Raw(func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		record := binaryValue(jsonRecord(conf, level, msg, props))
+		buf := encode(make([]byte, 4, 256), record)
+		binary.BigEndian.PutUint32(buf, uint32(len(buf)-4))
+		return string(buf)
+	})
+}
+
+// binaryValue replaces rawJSON in a normalized value with the value it encodes, so binary formats
+// write structs as maps instead of JSON strings.
+func binaryValue(value any) any {
+	switch v := value.(type) {
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = binaryValue(item)
+		}
+		return list
+	case object:
+		obj := make(object, len(v))
+		for i, f := range v {
+			obj[i] = field{name: f.name, value: binaryValue(f.value)}
+		}
+		return obj
+	case rawJSON:
+		dec := json.NewDecoder(bytes.NewReader(v))
+		dec.UseNumber()
+		if decoded, err := decodeJSONValue(dec); err == nil {
+			return decoded
+		}
+		return string(v)
+	}
+	return value
+}
+
+func decodeJSONValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch v := tok.(type) {
+	case json.Delim:
+		if v == '[' {
+			list := []any{}
+			for dec.More() {
+				item, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, item)
+			}
+			_, err := dec.Token()
+			return list, err
+		}
+		obj := object{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, field{name: key.(string), value: value})
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return u, nil
+		}
+		return strconv.ParseFloat(string(v), 64)
+	}
+	return tok, nil
+}
+
+// RecordDecoder reads length-prefixed records written by CBORFormat or MsgPackFormat.
+type RecordDecoder struct {
+	r      *bufio.Reader
+	decode func(*binaryReader) (any, error)
+}
+
+// NewCBORDecoder returns a RecordDecoder for the output of CBORFormat.
+func NewCBORDecoder(r io.Reader) *RecordDecoder {
+	return &RecordDecoder{r: bufio.NewReader(r), decode: decodeCBOR}
+}
+
+// NewMsgPackDecoder returns a RecordDecoder for the output of MsgPackFormat.
+func NewMsgPackDecoder(r io.Reader) *RecordDecoder {
+	return &RecordDecoder{r: bufio.NewReader(r), decode: decodeMsgPack}
+}
+
+// Decode reads the next record. Maps are returned as []logf.Prop, in order; other values are nil,
+// string, bool, int64, uint64, float64, []byte, or []any.
+// At the end of the stream, Decode returns io.EOF.
+func (dec *RecordDecoder) Decode() ([]logf.Prop, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(dec.r, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("%w: truncated frame header", BinarySyntaxError)
+		}
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MaxBinaryFrameSize {
+		return nil, BinaryFrameTooLargeError
+	}
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(dec.r, frame); err != nil {
+		return nil, fmt.Errorf("%w: truncated frame", BinarySyntaxError)
+	}
+	br := &binaryReader{buf: frame}
+	record, err := dec.decode(br)
+	if err != nil {
+		return nil, err
+	}
+	if len(br.buf) > 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", BinarySyntaxError, len(br.buf))
+	}
+	props, ok := record.([]logf.Prop)
+	if !ok {
+		return nil, fmt.Errorf("%w: record is not a map", BinarySyntaxError)
+	}
+	return props, nil
+}
+
+// binaryReader consumes a decoded frame.
+type binaryReader struct {
+	buf   []byte
+	depth int
+}
+
+func (br *binaryReader) next(n uint64) ([]byte, error) {
+	if n > uint64(len(br.buf)) {
+		return nil, fmt.Errorf("%w: unexpected end of record", BinarySyntaxError)
+	}
+	out := br.buf[:n]
+	br.buf = br.buf[n:]
+	return out, nil
+}
+
+func (br *binaryReader) byte() (byte, error) {
+	b, err := br.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// uint reads an n byte big-endian unsigned int.
+func (br *binaryReader) uint(n int) (uint64, error) {
+	b, err := br.next(uint64(n))
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// enter tracks nesting depth; call the returned func when leaving the container.
+func (br *binaryReader) enter() (func(), error) {
+	br.depth++
+	if br.depth > maxBinaryDepth {
+		return nil, fmt.Errorf("%w: nested too deeply", BinarySyntaxError)
+	}
+	return func() { br.depth-- }, nil
+}
+
+// checkCount rejects container lengths that can't fit in the rest of the record, since each
+// item takes at least one byte.
+func (br *binaryReader) checkCount(n uint64) error {
+	if n > uint64(len(br.buf)) {
+		return fmt.Errorf("%w: container length %d exceeds record", BinarySyntaxError, n)
+	}
+	return nil
+}
+
+func decodedKey(key any) string {
+	if str, ok := key.(string); ok {
+		return str
+	}
+	return fmt.Sprint(key)
+}
@@ -0,0 +1,215 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+	"github.com/decentplatforms/appkit/logf/testhelp"
+)
+
+type binaryCodec struct {
+	encode  func([]byte, any) []byte
+	decode  func(*binaryReader) (any, error)
+	format  func(JSONConfig) logf.Formatter
+	decoder func(io.Reader) *RecordDecoder
+}
+
+var binaryCodecs = map[string]binaryCodec{
+	"cbor":    {appendCBOR, decodeCBOR, CBORFormat, NewCBORDecoder},
+	"msgpack": {appendMsgPack, decodeMsgPack, MsgPackFormat, NewMsgPackDecoder},
+}
+
+func TestBinaryEncoding(t *testing.T) {
+	tests := map[string]any{
+		"zero":     int64(0),
+		"small":    int64(24),
+		"byte":     uint64(200),
+		"neg":      int64(-1),
+		"neg_big":  int64(-1000),
+		"max":      uint64(math.MaxUint64),
+		"float":    1.1,
+		"string":   "a",
+		"empty":    "",
+		"bytes":    []byte{1, 2},
+		"list":     []any{int64(1), int64(2), int64(3)},
+		"object":   object{{name: "a", value: int64(1)}},
+		"nil":      nil,
+		"true":     true,
+		"long_str": strings.Repeat("x", 300),
+	}
+	expects := testhelp.ResultsMap{
+		"cbor.zero":        "00",
+		"cbor.small":       "1818",
+		"cbor.byte":        "18c8",
+		"cbor.neg":         "20",
+		"cbor.neg_big":     "3903e7",
+		"cbor.max":         "1bffffffffffffffff",
+		"cbor.float":       "fb3ff199999999999a",
+		"cbor.string":      "6161",
+		"cbor.empty":       "60",
+		"cbor.bytes":       "420102",
+		"cbor.list":        "83010203",
+		"cbor.object":      "a1616101",
+		"cbor.nil":         "f6",
+		"cbor.true":        "f5",
+		"cbor.long_str":    "79012c",
+		"msgpack.zero":     "00",
+		"msgpack.small":    "18",
+		"msgpack.byte":     "ccc8",
+		"msgpack.neg":      "ff",
+		"msgpack.neg_big":  "d1fc18",
+		"msgpack.max":      "cfffffffffffffffff",
+		"msgpack.float":    "cb3ff199999999999a",
+		"msgpack.string":   "a161",
+		"msgpack.empty":    "a0",
+		"msgpack.bytes":    "c4020102",
+		"msgpack.list":     "93010203",
+		"msgpack.object":   "81a16101",
+		"msgpack.nil":      "c0",
+		"msgpack.true":     "c3",
+		"msgpack.long_str": "da012c",
+	}
+	res := testhelp.ResultsMap{}
+	for codecName, codec := range binaryCodecs {
+		for name, value := range tests {
+			key := codecName + "." + name
+			raw := codec.encode(nil, value)
+			if name == "long_str" {
+				res[key] = hex.EncodeToString(raw[:3])
+			} else {
+				res[key] = hex.EncodeToString(raw)
+			}
+
+			decoded, err := codec.decode(&binaryReader{buf: raw})
+			if err != nil {
+				t.Errorf("%s: %v", key, err)
+				continue
+			}
+			if obj, ok := value.(object); ok {
+				value = []logf.Prop{{Name: obj[0].name, Value: obj[0].value}}
+			}
+			// Decoders return int64 for any int that fits.
+			if u, ok := value.(uint64); ok && u <= math.MaxInt64 {
+				value = int64(u)
+			}
+			if fmt.Sprintf("%#v", decoded) != fmt.Sprintf("%#v", value) {
+				t.Errorf("%s: decoded %#v", key, decoded)
+			}
+		}
+	}
+	if err := testhelp.ValidateResults(res, expects); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCBORHalfFloat(t *testing.T) {
+	for encoded, wanted := range map[string]float64{
+		"f93c00": 1,
+		"f97bff": 65504,
+		"f90001": 5.960464477539063e-08,
+		"f9c400": -4,
+		"f97c00": math.Inf(1),
+	} {
+		raw, _ := hex.DecodeString(encoded)
+		decoded, err := decodeCBOR(&binaryReader{buf: raw})
+		if err != nil || decoded != wanted {
+			t.Errorf("%s: wanted %v, got %v (%v)", encoded, wanted, decoded, err)
+		}
+	}
+}
+
+func TestBinaryFormat(t *testing.T) {
+	props := func() []logf.Prop {
+		return []logf.Prop{
+			logf.String("property", "value\n"),
+			logf.Int("num", -5),
+			logf.Prop{Name: "time", Value: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)},
+			logf.Prop{Name: "struct", Value: jsonTestStruct{A: 1, B: "b"}},
+			logf.Prop{Name: "nan", Value: math.NaN()},
+		}
+	}
+	for name, codec := range binaryCodecs {
+		t.Run(name, func(t *testing.T) {
+			var stream bytes.Buffer
+			log, err := logf.NewLogger(logf.Config{
+				MaxLevel: logf.Debug,
+				Format:   codec.format(JSONConfig{TimestampKey: "-", Flatten: true}),
+				Output:   &stream,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			log.Log(logf.Warning, " first ", props()...)
+			log.Log(logf.Debug, "second")
+			if size := binary.BigEndian.Uint32(stream.Bytes()); int(size)+4 >= stream.Len() {
+				t.Fatalf("wrong frame size %d for %d bytes", size, stream.Len())
+			}
+
+			dec := codec.decoder(&stream)
+			first, err := dec.Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			wanted := `[{level 4} {level_str warn} {message  first } {property value` + "\n" +
+				`} {num -5} {time 2023-01-02T03:04:05Z} {struct [{a 1} {b b}]} {nan NaN}]`
+			if fmt.Sprint(first) != wanted {
+				t.Errorf("wrong first record:\n%v\n%v", first, wanted)
+			}
+			second, err := dec.Decode()
+			if err != nil || fmt.Sprint(second) != `[{level 7} {level_str debug} {message second}]` {
+				t.Errorf("wrong second record: %v (%v)", second, err)
+			}
+			if _, err := dec.Decode(); err != io.EOF {
+				t.Errorf("expected EOF, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRecordDecoderErrors(t *testing.T) {
+	frame := func(payload ...byte) []byte {
+		return append(binary.BigEndian.AppendUint32(nil, uint32(len(payload))), payload...)
+	}
+	tests := map[string][]byte{
+		"header":    {0, 0},
+		"truncated": frame(0xa1)[:4],
+		"not_map":   frame(0x01),
+		"trailing":  frame(0xa0, 0x00),
+		"short":     frame(0xa1, 0x61),
+		"count":     frame(0xbb, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff),
+		"deep":      frame(append([]byte{0xa1, 0x60}, bytes.Repeat([]byte{0x81}, 200)...)...),
+		"tags":      frame(append(bytes.Repeat([]byte{0xc0}, 100000), 0xa0)...),
+	}
+	for name, raw := range tests {
+		if _, err := NewCBORDecoder(bytes.NewReader(raw)).Decode(); !errors.Is(err, BinarySyntaxError) {
+			t.Errorf("%s: expected BinarySyntaxError, got %v", name, err)
+		}
+	}
+	big := binary.BigEndian.AppendUint32(nil, MaxBinaryFrameSize+1)
+	if _, err := NewMsgPackDecoder(bytes.NewReader(big)).Decode(); err != BinaryFrameTooLargeError {
+		t.Errorf("expected BinaryFrameTooLargeError, got %v", err)
+	}
+}
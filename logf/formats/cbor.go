@@ -0,0 +1,225 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+// CBOR major types.
+const (
+	cborUint   = 0 << 5
+	cborNegInt = 1 << 5
+	cborBytes  = 2 << 5
+	cborText   = 3 << 5
+	cborArray  = 4 << 5
+	cborMap    = 5 << 5
+	cborTag    = 6 << 5
+	cborSimple = 7 << 5
+)
+
+// CBORFormat provides the JSONFormat record encoded as RFC 8949 CBOR, using the same conf.
+// Each record is one map, prefixed with its length as a 4 byte big-endian uint, and isn't
+// followed by a newline. Read records back with NewCBORDecoder.
+//
+// Values are encoded as in JSONFormat, except that []byte is a byte string, floats are always
+// 64 bit (including NaN and infinities), and structs are maps.
+func CBORFormat(conf JSONConfig) logf.Formatter {
+	return binaryFormat(conf, appendCBOR)
+}
+
+func appendCBORHead(buf []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(buf, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, major|26), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, major|27), n)
+}
+
+func appendCBOR(buf []byte, value any) []byte {
+	switch v := value.(type) {
+	case nil:
+		return append(buf, cborSimple|22)
+	case bool:
+		if v {
+			return append(buf, cborSimple|21)
+		}
+		return append(buf, cborSimple|20)
+	case int64:
+		if v < 0 {
+			return appendCBORHead(buf, cborNegInt, uint64(-1-v))
+		}
+		return appendCBORHead(buf, cborUint, uint64(v))
+	case uint64:
+		return appendCBORHead(buf, cborUint, v)
+	case float64:
+		return binary.BigEndian.AppendUint64(append(buf, cborSimple|27), math.Float64bits(v))
+	case string:
+		return append(appendCBORHead(buf, cborText, uint64(len(v))), v...)
+	case []byte:
+		return append(appendCBORHead(buf, cborBytes, uint64(len(v))), v...)
+	case []any:
+		buf = appendCBORHead(buf, cborArray, uint64(len(v)))
+		for _, item := range v {
+			buf = appendCBOR(buf, item)
+		}
+		return buf
+	case object:
+		buf = appendCBORHead(buf, cborMap, uint64(len(v)))
+		for _, f := range v {
+			buf = appendCBOR(buf, f.name)
+			buf = appendCBOR(buf, f.value)
+		}
+		return buf
+	}
+	return appendCBOR(buf, fmt.Sprint(value))
+}
+
+// decodeCBOR decodes one CBOR data item. Tags are skipped, and indefinite lengths aren't supported.
+func decodeCBOR(br *binaryReader) (any, error) {
+	initial, err := br.byte()
+	if err != nil {
+		return nil, err
+	}
+	major, info := initial&0xe0, initial&0x1f
+	if major == cborSimple {
+		return decodeCBORSimple(br, info)
+	}
+
+	var n uint64
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info <= 27:
+		n, err = br.uint(1 << (info - 24))
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported CBOR length 0x%02x", BinarySyntaxError, initial)
+	}
+
+	switch major {
+	case cborUint:
+		if n <= math.MaxInt64 {
+			return int64(n), nil
+		}
+		return n, nil
+	case cborNegInt:
+		if n > math.MaxInt64 {
+			return nil, fmt.Errorf("%w: CBOR negative int out of range", BinarySyntaxError)
+		}
+		return -1 - int64(n), nil
+	case cborBytes:
+		b, err := br.next(n)
+		return append([]byte{}, b...), err
+	case cborText:
+		b, err := br.next(n)
+		return string(b), err
+	case cborTag:
+		// Tags are skipped, but count toward the depth limit, since each one nests the item after it.
+		leave, err := br.enter()
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+		return decodeCBOR(br)
+	}
+
+	leave, err := br.enter()
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+	if err := br.checkCount(n); err != nil {
+		return nil, err
+	}
+	if major == cborArray {
+		list := make([]any, n)
+		for i := range list {
+			if list[i], err = decodeCBOR(br); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+	props := make([]logf.Prop, n)
+	for i := range props {
+		key, err := decodeCBOR(br)
+		if err != nil {
+			return nil, err
+		}
+		value, err := decodeCBOR(br)
+		if err != nil {
+			return nil, err
+		}
+		props[i] = logf.Prop{Name: decodedKey(key), Value: value}
+	}
+	return props, nil
+}
+
+func decodeCBORSimple(br *binaryReader, info byte) (any, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		bits, err := br.uint(2)
+		return halfFloat(uint16(bits)), err
+	case 26:
+		bits, err := br.uint(4)
+		return float64(math.Float32frombits(uint32(bits))), err
+	case 27:
+		bits, err := br.uint(8)
+		return math.Float64frombits(bits), err
+	}
+	return nil, fmt.Errorf("%w: unsupported CBOR simple value %d", BinarySyntaxError, info)
+}
+
+// halfFloat converts an IEEE 754 half-precision float.
+func halfFloat(bits uint16) float64 {
+	exp := int(bits>>10) & 0x1f
+	mant := float64(bits & 0x3ff)
+	var v float64
+	switch exp {
+	case 0:
+		v = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			v = math.Inf(1)
+		} else {
+			v = math.NaN()
+		}
+	default:
+		v = math.Ldexp(mant+1024, exp-25)
+	}
+	if bits&0x8000 != 0 {
+		return -v
+	}
+	return v
+}
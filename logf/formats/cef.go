@@ -0,0 +1,216 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+const (
+	CEF_SIGNATURE_ID = string("cef_signature_id")
+	LEEF_EVENT_ID    = string("leef_event_id")
+)
+
+// SIEMSeverity maps a LogLevel onto the 0-10 severity used by CEF and LEEF, where 10 is the most
+// severe. Levels outside of the syslog range are clamped to the nearest syslog level.
+func SIEMSeverity(level logf.LogLevel) int {
+	switch {
+	case level <= logf.Emergency:
+		return 10
+	case level == logf.Alert:
+		return 9
+	case level == logf.Critical:
+		return 8
+	case level == logf.Error:
+		return 7
+	case level == logf.Warning:
+		return 5
+	case level == logf.Notice:
+		return 4
+	case level == logf.Informational:
+		return 3
+	default:
+		return 1
+	}
+}
+
+// CEFConfig sets default values for CEFFormat.
+// Usage notes:
+//   - Vendor defaults to logf, Product to the program name, and Version to 1.0
+//   - SignatureID is the Device Event Class ID, and defaults to log. The CEF_SIGNATURE_ID prop
+//     overrides it per message.
+type CEFConfig struct {
+	Vendor      string
+	Product     string
+	Version     string
+	SignatureID string
+}
+
+func (conf CEFConfig) withDefaults() CEFConfig {
+	if conf.Vendor == "" {
+		conf.Vendor = "logf"
+	}
+	if conf.Product == "" {
+		conf.Product = filepath.Base(os.Args[0])
+	}
+	if conf.Version == "" {
+		conf.Version = "1.0"
+	}
+	if conf.SignatureID == "" {
+		conf.SignatureID = "log"
+	}
+	return conf
+}
+
+// CEFFormat provides ArcSight Common Event Format, version 0:
+//   - The header has conf.Vendor, conf.Product, conf.Version, the signature ID, the log message
+//     as the event name, and the level's SIEMSeverity
+//   - rt is the current time, in milliseconds since the epoch
+//   - Props are extension fields, in order. Keys are limited to letters, digits, _, and ., so
+//     other characters are replaced with _. Groups are written with dotted keys, like http.method.
+//
+// Header fields escape \ and |, and extension values escape \ and =, and write newlines as \n.
+func CEFFormat(conf CEFConfig) logf.Formatter {
+	conf = conf.withDefaults()
+	header := "CEF:0|" + cefHeader(conf.Vendor) + "|" + cefHeader(conf.Product) + "|" + cefHeader(conf.Version) + "|"
+	return func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		var out strings.Builder
+		out.WriteString(header)
+		out.WriteString(cefHeader(propString(props, CEF_SIGNATURE_ID, conf.SignatureID)))
+		out.WriteByte('|')
+		out.WriteString(cefHeader(msg))
+		out.WriteByte('|')
+		out.WriteString(strconv.Itoa(SIEMSeverity(level)))
+		out.WriteString("|rt=")
+		out.WriteString(strconv.FormatInt(time.Now().UnixMilli(), 10))
+		for _, prop := range flattenGroups(props.Without(CEF_SIGNATURE_ID).Slice()) {
+			out.WriteByte(' ')
+			out.WriteString(siemKey(prop.Name))
+			out.WriteByte('=')
+			out.WriteString(cefValue(textValue(prop.Value)))
+		}
+		return out.String()
+	}
+}
+
+var cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r\n", " ", "\n", " ", "\r", " ")
+var cefValueEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+
+func cefHeader(str string) string {
+	return cefHeaderEscaper.Replace(str)
+}
+
+func cefValue(str string) string {
+	return cefValueEscaper.Replace(str)
+}
+
+// siemKey replaces characters CEF and LEEF don't allow in keys with _.
+func siemKey(name string) string {
+	if name == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// propString returns a named prop as a string if it's a string or an integer, or def.
+func propString(props logf.PropGetter, name string, def string) string {
+	switch v := props.Get(name).(type) {
+	case string:
+		if v != "" {
+			return v
+		}
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v)
+	}
+	return def
+}
+
+// LEEFConfig sets default values for LEEFFormat.
+// Usage notes:
+//   - Vendor defaults to logf, Product to the program name, and Version to 1.0
+//   - EventID defaults to log. The LEEF_EVENT_ID prop overrides it per message.
+//   - Delimiter separates attributes, and defaults to a tab
+type LEEFConfig struct {
+	Vendor    string
+	Product   string
+	Version   string
+	EventID   string
+	Delimiter rune
+}
+
+func (conf LEEFConfig) withDefaults() LEEFConfig {
+	if conf.Vendor == "" {
+		conf.Vendor = "logf"
+	}
+	if conf.Product == "" {
+		conf.Product = filepath.Base(os.Args[0])
+	}
+	if conf.Version == "" {
+		conf.Version = "1.0"
+	}
+	if conf.EventID == "" {
+		conf.EventID = "log"
+	}
+	if conf.Delimiter == 0 {
+		conf.Delimiter = '\t'
+	}
+	return conf
+}
+
+// LEEFFormat provides IBM QRadar Log Event Extended Format, version 2.0:
+//   - The header has conf.Vendor, conf.Product, conf.Version, the event ID, and the delimiter, as
+//     a hex value like x09
+//   - devTime is the current time in UTC, in the default LEEF layout with milliseconds
+//   - sev is the level's SIEMSeverity, and msg is the log message
+//   - Props are attributes, in order. Keys are limited to letters, digits, _, and ., so other
+//     characters are replaced with _. Groups are written with dotted keys, like http.method.
+//
+// Header fields escape \ and |, and values escape \ and the delimiter, and write newlines as \n.
+func LEEFFormat(conf LEEFConfig) logf.Formatter {
+	conf = conf.withDefaults()
+	delim := string(conf.Delimiter)
+	header := fmt.Sprintf("LEEF:2.0|%s|%s|%s|", cefHeader(conf.Vendor), cefHeader(conf.Product), cefHeader(conf.Version))
+	escaper := strings.NewReplacer(`\`, `\\`, delim, `\`+delim, "\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+	return func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		var out strings.Builder
+		out.WriteString(header)
+		out.WriteString(cefHeader(propString(props, LEEF_EVENT_ID, conf.EventID)))
+		fmt.Fprintf(&out, "|x%02x|", conf.Delimiter)
+		out.WriteString("devTime=")
+		out.WriteString(time.Now().UTC().Format("Jan 02 2006 15:04:05.000 MST"))
+		out.WriteString(delim + "devTimeFormat=MMM dd yyyy HH:mm:ss.SSS z")
+		out.WriteString(delim + "sev=" + strconv.Itoa(SIEMSeverity(level)))
+		out.WriteString(delim + "msg=" + escaper.Replace(msg))
+		for _, prop := range flattenGroups(props.Without(LEEF_EVENT_ID).Slice()) {
+			out.WriteString(delim)
+			out.WriteString(siemKey(prop.Name))
+			out.WriteByte('=')
+			out.WriteString(escaper.Replace(textValue(prop.Value)))
+		}
+		return out.String()
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+func siemProps() *logf.Props {
+	return logf.NewProps(
+		logf.String("src", "10.0.0.1"),
+		logf.String("tricky", "a=b\\c|d\ne\tf"),
+		logf.String("bad key", "x"),
+	)
+}
+
+func TestCEFFormat(t *testing.T) {
+	format := CEFFormat(CEFConfig{Vendor: "Ven|dor", Product: "app", Version: "2.1"})
+	out := format.FormatAndNormalize(logf.Error, "login\nfailed|twice", siemProps())
+	wanted := regexp.MustCompile(`^CEF:0\|Ven\\\|dor\|app\|2\.1\|log\|login failed\\\|twice\|7\|rt=\d+ src=10\.0\.0\.1 tricky=a\\=b\\\\c\|d\\ne\tf bad_key=x` + "\n$")
+	if !wanted.MatchString(out) {
+		t.Errorf("wrong CEF: %q", out)
+	}
+
+	props := logf.NewProps(logf.Int(CEF_SIGNATURE_ID, 100))
+	if out := format.FormatAndNormalize(logf.Debug, "msg", props); !strings.HasPrefix(out, "CEF:0|Ven\\|dor|app|2.1|100|msg|1|") {
+		t.Errorf("wrong signature ID: %q", out)
+	}
+}
+
+func TestLEEFFormat(t *testing.T) {
+	format := LEEFFormat(LEEFConfig{Vendor: "vendor", Product: "app", Version: "2.1", Delimiter: '^'})
+	out := format.FormatAndNormalize(logf.Warning, "login^failed", siemProps())
+	wanted := regexp.MustCompile(`^LEEF:2\.0\|vendor\|app\|2\.1\|log\|x5e\|devTime=\w{3} \d\d \d{4} \d\d:\d\d:\d\d\.\d{3} UTC\^` +
+		`devTimeFormat=MMM dd yyyy HH:mm:ss\.SSS z\^sev=5\^msg=login\\\^failed\^src=10\.0\.0\.1\^tricky=a=b\\\\c\|d\\ne\tf\^bad_key=x` + "\n$")
+	if !wanted.MatchString(out) {
+		t.Errorf("wrong LEEF: %q", out)
+	}
+
+	tabbed := LEEFFormat(LEEFConfig{}).FormatAndNormalize(logf.Informational, "msg", logf.NewProps(logf.String(LEEF_EVENT_ID, "auth")))
+	if !strings.Contains(tabbed, "|auth|x09|devTime=") || !strings.Contains(tabbed, "\tsev=3\tmsg=msg\n") {
+		t.Errorf("wrong default LEEF: %q", tabbed)
+	}
+}
+
+func TestSIEMGroups(t *testing.T) {
+	props := func() *logf.Props {
+		return logf.NewProps(logf.Group("http", logf.String("method", "GET"), logf.Group("client", logf.String("ip", "10.0.0.1"))))
+	}
+	cef := CEFFormat(CEFConfig{}).FormatAndNormalize(logf.Warning, "msg", props())
+	if !strings.HasSuffix(cef, " http.method=GET http.client.ip=10.0.0.1\n") {
+		t.Errorf("wrong CEF groups: %q", cef)
+	}
+	leef := LEEFFormat(LEEFConfig{}).FormatAndNormalize(logf.Warning, "msg", props())
+	if !strings.HasSuffix(leef, "\thttp.method=GET\thttp.client.ip=10.0.0.1\n") {
+		t.Errorf("wrong LEEF groups: %q", leef)
+	}
+}
+
+func TestSIEMSeverity(t *testing.T) {
+	last := 11
+	for level := logf.MOST_SEVERE; level <= logf.LEAST_SEVERE; level++ {
+		sev := SIEMSeverity(level)
+		if sev >= last || sev < 0 {
+			t.Errorf("severity for %s should be below %d, got %d", level, last, sev)
+		}
+		last = sev
+	}
+}
+
+func TestSyslog5424Wrap(t *testing.T) {
+	format := Syslog5424Wrap(SyslogConfig{Hostname: "host", AppName: "app", Facility: 4}, CEFFormat(CEFConfig{Vendor: "v", Product: "p", Version: "1"}))
+	props := logf.NewProps(logf.String(SYSLOG_TAG, "sec"), logf.String("src", "10.0.0.1"))
+	out := format.FormatAndNormalize(logf.Alert, "intrusion", props)
+	wanted := regexp.MustCompile(`^<33>1 \S+ host app \d+ sec - CEF:0\|v\|p\|1\|log\|intrusion\|9\|rt=\d+ src=10\.0\.0\.1` + "\n$")
+	if !wanted.MatchString(out) {
+		t.Errorf("wrong wrapped CEF: %q", out)
+	}
+}
+
+func TestSyslog5424WrapGroups(t *testing.T) {
+	format := Syslog5424Wrap(SyslogConfig{Hostname: "host", AppName: "app", Facility: 4}, CEFFormat(CEFConfig{Vendor: "v", Product: "p", Version: "1"}))
+	props := logf.NewProps(logf.Group("http", logf.String("method", "GET")), logf.String("src", "10.0.0.1"))
+	out := format.FormatAndNormalize(logf.Alert, "intrusion", props)
+	wanted := regexp.MustCompile(`^<33>1 \S+ host app \d+ log \[http method="GET"\] CEF:0\|v\|p\|1\|log\|intrusion\|9\|rt=\d+ src=10\.0\.0\.1` + "\n$")
+	if !wanted.MatchString(out) {
+		t.Errorf("wanted groups only in STRUCTURED-DATA, got %q", out)
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+// GCPConfig sets default values for GCPFormat.
+// Usage notes:
+//   - JSONConfig works as it does for JSONFormat, except that TimestampKey defaults to time,
+//     TimeFormat defaults to time.RFC3339Nano, and LevelKey and LevelStrKey default to "-"
+//   - ProjectID is used to write trace IDs as projects/ProjectID/traces/TRACE_ID. If it's empty,
+//     trace IDs are written as-is.
+type GCPConfig struct {
+	JSONConfig
+	ProjectID string
+}
+
+func (conf GCPConfig) withDefaults() GCPConfig {
+	if conf.TimestampKey == "" {
+		conf.TimestampKey = "time"
+	}
+	if conf.TimeFormat == "" {
+		conf.TimeFormat = time.RFC3339Nano
+	}
+	if conf.LevelKey == "" {
+		conf.LevelKey = "-"
+	}
+	if conf.LevelStrKey == "" {
+		conf.LevelStrKey = "-"
+	}
+	conf.JSONConfig = conf.JSONConfig.withDefaults()
+	return conf
+}
+
+// GCPSeverity maps a LogLevel onto its Cloud Logging LogSeverity name.
+// Levels outside of the syslog range are clamped to the nearest syslog level.
+func GCPSeverity(level logf.LogLevel) string {
+	switch {
+	case level <= logf.Emergency:
+		return "EMERGENCY"
+	case level == logf.Alert:
+		return "ALERT"
+	case level == logf.Critical:
+		return "CRITICAL"
+	case level == logf.Error:
+		return "ERROR"
+	case level == logf.Warning:
+		return "WARNING"
+	case level == logf.Notice:
+		return "NOTICE"
+	case level == logf.Informational:
+		return "INFO"
+	default:
+		return "DEBUG"
+	}
+}
+
+// GCPFormat provides the JSON that Google Cloud Logging's agents parse from stdout, written as
+// JSONFormat writes it with these special fields after the message:
+//   - severity is the level's GCPSeverity
+//   - logging.googleapis.com/trace is the OTEL_TRACE_ID prop, and logging.googleapis.com/spanId is
+//     the OTEL_SPAN_ID prop
+//   - logging.googleapis.com/sourceLocation is the logf.SOURCE prop, if it's a logf.Frame
+//
+// Props used for special fields aren't written again with the other props.
+func GCPFormat(conf GCPConfig) logf.Formatter {
+	conf = conf.withDefaults()
+	return func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		extra := object{{name: "severity", value: GCPSeverity(level)}}
+		if trace := propString(props, OTEL_TRACE_ID, ""); trace != "" {
+			if conf.ProjectID != "" {
+				trace = "projects/" + conf.ProjectID + "/traces/" + trace
+			}
+			extra = append(extra, field{name: "logging.googleapis.com/trace", value: trace})
+		}
+		if span := propString(props, OTEL_SPAN_ID, ""); span != "" {
+			extra = append(extra, field{name: "logging.googleapis.com/spanId", value: span})
+		}
+		omit := []string{OTEL_TRACE_ID, OTEL_SPAN_ID}
+		if frame, ok := props.Get(logf.SOURCE).(logf.Frame); ok {
+			// LogEntrySourceLocation.line is an int64, which is a string in the proto3 JSON mapping.
+			extra = append(extra, field{name: "logging.googleapis.com/sourceLocation", value: object{
+				{name: "file", value: frame.File},
+				{name: "line", value: strconv.Itoa(frame.Line)},
+				{name: "function", value: frame.Function},
+			}})
+			omit = append(omit, logf.SOURCE)
+		}
+		return string(appendJSON(nil, jsonRecord(conf.JSONConfig, level, msg, props.Without(omit...), extra...)))
+	}
+}
+
+// EMFMetric declares a prop as a CloudWatch metric.
+// Usage notes:
+//   - Name is the prop name, which is also the metric name
+//   - Unit is a CloudWatch unit, like Milliseconds or Count, and defaults to None
+//   - StorageResolution is 1 for high-resolution metrics, or 0 for the standard 60 seconds
+type EMFMetric struct {
+	Name              string
+	Unit              string
+	StorageResolution int
+}
+
+// EMFConfig sets default values for EMFFormat.
+// Usage notes:
+//   - JSONConfig works as it does for JSONFormat, except that Flatten is always on, since EMF
+//     reads metric and dimension values from the top-level object
+//   - Namespace is the CloudWatch namespace, and defaults to logf
+//   - Metrics are the props to declare as metrics
+//   - Dimensions are sets of prop names to use as dimensions, like [][]string{{"service", "route"}}
+type EMFConfig struct {
+	JSONConfig
+	Namespace  string
+	Metrics    []EMFMetric
+	Dimensions [][]string
+}
+
+func (conf EMFConfig) withDefaults() EMFConfig {
+	conf.Flatten = true
+	conf.JSONConfig = conf.JSONConfig.withDefaults()
+	if conf.Namespace == "" {
+		conf.Namespace = "logf"
+	}
+	for i, metric := range conf.Metrics {
+		if metric.Unit == "" {
+			conf.Metrics[i].Unit = "None"
+		}
+	}
+	return conf
+}
+
+// EMFFormat provides CloudWatch Embedded Metric Format, which is the JSONFormat record with an
+// _aws object that declares metrics:
+//   - Metrics in conf.Metrics are declared when their prop is a finite number. If no metrics are
+//     declared, the record is written without _aws.
+//   - Dimension sets in conf.Dimensions are declared when every prop in the set is present, and
+//     their values are written as strings.
+//   - A prop named _aws is prefixed with _, like props that collide with JSONFormat's keys
+func EMFFormat(conf EMFConfig) logf.Formatter {
+	conf.Metrics = append([]EMFMetric{}, conf.Metrics...)
+	conf = conf.withDefaults()
+	return func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		now := time.Now()
+		record := jsonRecord(conf.JSONConfig, level, msg, props)
+		index := make(map[string]int, len(record))
+		for i, f := range record {
+			index[f.name] = i
+		}
+		// _aws is reserved for the metadata, even when no metrics are declared, so CloudWatch
+		// doesn't read a prop as metadata.
+		if i, ok := index["_aws"]; ok {
+			name := "_aws"
+			for _, taken := index[name]; taken; _, taken = index[name] {
+				name = "_" + name
+			}
+			record[i].name = name
+			index[name] = i
+			delete(index, "_aws")
+		}
+
+		metrics := []any{}
+		for _, metric := range conf.Metrics {
+			i, ok := index[metric.Name]
+			if !ok || !emfNumber(record[i].value) {
+				continue
+			}
+			def := object{{name: "Name", value: metric.Name}, {name: "Unit", value: metric.Unit}}
+			if metric.StorageResolution > 0 {
+				def = append(def, field{name: "StorageResolution", value: int64(metric.StorageResolution)})
+			}
+			metrics = append(metrics, def)
+		}
+		if len(metrics) == 0 {
+			return string(appendJSON(nil, record))
+		}
+
+		dimensions := []any{}
+	sets:
+		for _, set := range conf.Dimensions {
+			names := make([]any, len(set))
+			for j, name := range set {
+				if _, ok := index[name]; !ok {
+					continue sets
+				}
+				names[j] = name
+			}
+			for _, name := range set {
+				f := &record[index[name]]
+				if _, ok := f.value.(string); !ok {
+					f.value = fmt.Sprint(f.value)
+				}
+			}
+			dimensions = append(dimensions, names)
+		}
+
+		aws := object{
+			{name: "Timestamp", value: now.UnixMilli()},
+			{name: "CloudWatchMetrics", value: []any{object{
+				{name: "Namespace", value: conf.Namespace},
+				{name: "Dimensions", value: dimensions},
+				{name: "Metrics", value: metrics},
+			}}},
+		}
+		return string(appendJSON(nil, append(object{{name: "_aws", value: aws}}, record...)))
+	}
+}
+
+func emfNumber(value any) bool {
+	switch v := value.(type) {
+	case int64, uint64:
+		return true
+	case float64:
+		return !math.IsNaN(v) && !math.IsInf(v, 0)
+	}
+	return false
+}
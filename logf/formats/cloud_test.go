@@ -0,0 +1,143 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+func TestGCPFormat(t *testing.T) {
+	format := GCPFormat(GCPConfig{ProjectID: "my-project", JSONConfig: JSONConfig{Flatten: true}})
+	props := logf.NewProps(
+		logf.String(OTEL_TRACE_ID, "abc123"),
+		logf.String(OTEL_SPAN_ID, "def456"),
+		logf.Prop{Name: logf.SOURCE, Value: logf.Frame{Function: "main.run", File: "/src/main.go", Line: 42}},
+		logf.String("user", "u1"),
+	)
+	out := format.FormatAndNormalize(logf.Critical, "test log", props)
+	wanted := `,"message":"test log","severity":"CRITICAL",` +
+		`"logging.googleapis.com/trace":"projects/my-project/traces/abc123","logging.googleapis.com/spanId":"def456",` +
+		`"logging.googleapis.com/sourceLocation":{"file":"/src/main.go","line":"42","function":"main.run"},"user":"u1"}` + "\n"
+	if !strings.HasPrefix(out, `{"time":"`) || !strings.HasSuffix(out, wanted) {
+		t.Errorf("wrong GCP record: %s", out)
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(out), &record); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, record["time"].(string)); err != nil {
+		t.Error(err)
+	}
+
+	out = GCPFormat(GCPConfig{}).FormatAndNormalize(logf.Debug, "test log", logf.NewProps(logf.String(logf.SOURCE, "not a frame")))
+	if !strings.HasSuffix(out, `"message":"test log","severity":"DEBUG","props":{"source":"not a frame"}}`+"\n") {
+		t.Errorf("wrong GCP record without special props: %s", out)
+	}
+}
+
+func TestEMFFormat(t *testing.T) {
+	format := EMFFormat(EMFConfig{
+		JSONConfig: JSONConfig{TimestampKey: "-"},
+		Namespace:  "app",
+		Metrics: []EMFMetric{
+			{Name: "latency", Unit: "Milliseconds", StorageResolution: 1},
+			{Name: "count"},
+			{Name: "missing"},
+			{Name: "text"},
+		},
+		Dimensions: [][]string{{"service", "code"}, {"service", "missing"}},
+	})
+	props := logf.NewProps(
+		logf.String("service", "api"),
+		logf.Int("code", 200),
+		logf.Float("latency", 12.5),
+		logf.Int("count", 1),
+		logf.String("text", "12"),
+	)
+	out := format.FormatAndNormalize(logf.Informational, "request", props)
+	var record struct {
+		AWS struct {
+			Timestamp         int64
+			CloudWatchMetrics []struct {
+				Namespace  string
+				Dimensions [][]string
+				Metrics    []map[string]any
+			}
+		} `json:"_aws"`
+		Service string
+		Code    string
+		Latency float64
+		Message string
+	}
+	if err := json.Unmarshal([]byte(out), &record); err != nil {
+		t.Fatal(out, err)
+	}
+	if !strings.HasPrefix(out, `{"_aws":{`) || math.Abs(float64(time.Now().UnixMilli()-record.AWS.Timestamp)) > 60000 {
+		t.Errorf("wrong _aws header: %s", out)
+	}
+	cwm := record.AWS.CloudWatchMetrics
+	if len(cwm) != 1 || cwm[0].Namespace != "app" {
+		t.Fatalf("wrong CloudWatchMetrics: %s", out)
+	}
+	if len(cwm[0].Dimensions) != 1 || strings.Join(cwm[0].Dimensions[0], ",") != "service,code" || record.Code != "200" {
+		t.Errorf("wrong dimensions: %s", out)
+	}
+	if len(cwm[0].Metrics) != 2 || cwm[0].Metrics[0]["Unit"] != "Milliseconds" || cwm[0].Metrics[0]["StorageResolution"] != 1.0 || cwm[0].Metrics[1]["Unit"] != "None" {
+		t.Errorf("wrong metrics: %s", out)
+	}
+	if record.Latency != 12.5 || record.Service != "api" || record.Message != "request" {
+		t.Errorf("wrong values: %s", out)
+	}
+
+	out = format.FormatAndNormalize(logf.Informational, "no metrics", logf.NewProps(logf.String("service", "api")))
+	if strings.Contains(out, "_aws") {
+		t.Errorf("record without metrics shouldn't have _aws: %s", out)
+	}
+}
+
+func TestEMFReservedKey(t *testing.T) {
+	format := EMFFormat(EMFConfig{JSONConfig: JSONConfig{TimestampKey: "-"}, Metrics: []EMFMetric{{Name: "count"}}})
+	out := format.FormatAndNormalize(logf.Informational, "request", logf.NewProps(
+		logf.Int("count", 1), logf.String("_aws", "user"), logf.String("__aws", "taken"),
+	))
+	if !strings.HasPrefix(out, `{"_aws":{"Timestamp":`) || !strings.Contains(out, `"___aws":"user","__aws":"taken"`) {
+		t.Errorf("wrong reserved key with metrics: %s", out)
+	}
+	out = format.FormatAndNormalize(logf.Informational, "request", logf.NewProps(logf.String("_aws", "user")))
+	if strings.Contains(out, `"_aws"`) || !strings.Contains(out, `"__aws":"user"`) {
+		t.Errorf("wrong reserved key without metrics: %s", out)
+	}
+}
+
+func TestGCPNamespace(t *testing.T) {
+	var out strings.Builder
+	log, _ := logf.NewLogger(logf.Config{
+		MaxLevel: logf.Debug,
+		Format:   GCPFormat(GCPConfig{ProjectID: "my-project", JSONConfig: JSONConfig{TimestampKey: "-"}}),
+		Output:   &out,
+	})
+	logf.Namespace(log, "http").Log(logf.Informational, "test log", logf.String(OTEL_TRACE_ID, "abc123"), logf.String("path", "/"))
+	wanted := `{"message":"test log","severity":"INFO","logging.googleapis.com/trace":"projects/my-project/traces/abc123",` +
+		`"props":{"http":{"path":"/"}}}` + "\n"
+	if out.String() != wanted {
+		t.Errorf("namespaced trace ID wasn't found: %s", out.String())
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+type ConsoleColor int
+
+const (
+	// ColorAuto uses colors if ConsoleConfig.Output is a terminal and NO_COLOR isn't set.
+	ColorAuto = ConsoleColor(iota)
+	ColorAlways
+	ColorNever
+)
+
+var consoleColorNames = map[string]ConsoleColor{
+	"auto":   ColorAuto,
+	"always": ColorAlways,
+	"never":  ColorNever,
+}
+
+// UnmarshalText sets color from auto, always, or never, so it can be set from a config file.
+func (color *ConsoleColor) UnmarshalText(text []byte) error {
+	value, ok := consoleColorNames[string(text)]
+	if !ok {
+		return fmt.Errorf("unknown console color %q", text)
+	}
+	*color = value
+	return nil
+}
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiDim   = "\x1b[2m"
+)
+
+var consoleLevelColors = map[logf.LogLevel]string{
+	logf.Emergency:     "\x1b[1;97;41m",
+	logf.Alert:         "\x1b[1;97;41m",
+	logf.Critical:      "\x1b[1;31m",
+	logf.Error:         "\x1b[31m",
+	logf.Warning:       "\x1b[33m",
+	logf.Notice:        "\x1b[36m",
+	logf.Informational: "\x1b[32m",
+	logf.Debug:         "\x1b[90m",
+}
+
+// ConsoleConfig sets default values for ConsoleFormat.
+// Usage notes:
+//   - TimeFormat defaults to 15:04:05.000, in local time
+//   - Color defaults to ColorAuto
+//   - Output is only used to detect a terminal for ColorAuto, and defaults to os.Stdout. Set it to the
+//     logger's output.
+//   - LevelNames names levels for the badge, instead of the logger's Config.LevelNames
+type ConsoleConfig struct {
+	TimeFormat string
+	Color      ConsoleColor
+	Output     io.Writer
+	LevelNames *logf.LevelNames
+}
+
+func (conf ConsoleConfig) withDefaults() ConsoleConfig {
+	if conf.TimeFormat == "" {
+		conf.TimeFormat = "15:04:05.000"
+	}
+	if conf.Output == nil {
+		conf.Output = os.Stdout
+	}
+	return conf
+}
+
+func (conf ConsoleConfig) useColor() bool {
+	switch conf.Color {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(conf.Output)
+}
+
+func isTerminal(output io.Writer) bool {
+	file, ok := output.(*os.File)
+	if !ok {
+		return false
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
+
+// ConsoleFormat provides human-friendly output for local development, like
+// "15:04:05.000 info   message key=value":
+//   - The level badge is the level keyword, padded to align messages across levels
+//   - Lines after the first in multi-line messages are indented to line up with the first
+//   - Props are written as key=value after the message, and dimmed when colors are on. Props in a
+//     logf.Group have dotted keys, like http.method.
+//   - Stacks from logf.Err and the logf.STACK prop are written after the props, one frame per
+//     indented line
+//   - The logf.SOURCE frame from Config.AddCaller is written after the props, as the file's
+//     directory and name, and line number
+//
+// Whether to use colors is decided when the format is created.
+func ConsoleFormat(conf ConsoleConfig) logf.Formatter {
+	conf = conf.withDefaults()
+	color := conf.useColor()
+	stampWidth := len(time.Now().Format(conf.TimeFormat))
+	return func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		var out strings.Builder
+		names := conf.LevelNames
+		if names == nil {
+			names = props.LevelNames()
+		}
+		width := names.MaxWidth()
+		indent := "\n" + strings.Repeat(" ", stampWidth+width+2)
+		badge := names.Name(level)
+		if pad := width - len(badge); pad > 0 {
+			badge += strings.Repeat(" ", pad)
+		}
+
+		out.WriteString(time.Now().Format(conf.TimeFormat))
+		out.WriteByte(' ')
+		if color {
+			out.WriteString(consoleLevelColors[max(logf.MOST_SEVERE, min(level, logf.LEAST_SEVERE))])
+			out.WriteString(badge)
+			out.WriteString(ansiReset)
+		} else {
+			out.WriteString(badge)
+		}
+		out.WriteByte(' ')
+		out.WriteString(strings.ReplaceAll(strings.TrimRight(msg, "\n"), "\n", indent))
+
+		source, hasSource := props.Get(logf.SOURCE).(logf.Frame)
+		if hasSource {
+			props = props.Without(logf.SOURCE)
+		}
+		stack, hasStack := props.Get(logf.STACK).([]logf.Frame)
+		if hasStack {
+			props = props.Without(logf.STACK)
+		}
+		if props.Len() > 0 {
+			out.WriteByte(' ')
+			if color {
+				out.WriteString(ansiDim)
+			}
+			for i, prop := range flattenGroups(props.Slice()) {
+				if i > 0 {
+					out.WriteByte(' ')
+				}
+				out.WriteString(LogfmtKey(prop.Name))
+				out.WriteByte('=')
+				out.WriteString(LogfmtValue(prop.Value))
+			}
+			if color {
+				out.WriteString(ansiReset)
+			}
+		}
+		if hasSource {
+			out.WriteByte(' ')
+			if color {
+				out.WriteString(ansiDim)
+			}
+			out.WriteString(shortSource(source))
+			if color {
+				out.WriteString(ansiReset)
+			}
+		}
+		stacks := [][]logf.Frame{}
+		for _, prop := range props.Slice() {
+			if errValue, ok := prop.Value.(*logf.ErrorValue); ok && errValue != nil {
+				stacks = append(stacks, errorStack(errValue))
+			}
+		}
+		if hasStack {
+			stacks = append(stacks, stack)
+		}
+		for _, frames := range stacks {
+			for _, frame := range frames {
+				out.WriteString(indent)
+				if color {
+					out.WriteString(ansiDim)
+				}
+				out.WriteString(frame.Function)
+				out.WriteByte(' ')
+				out.WriteString(frame.String())
+				if color {
+					out.WriteString(ansiReset)
+				}
+			}
+		}
+		return out.String()
+	}
+}
+
+// shortSource returns the frame's file as its directory and name, like logf/logf.go:42.
+func shortSource(frame logf.Frame) string {
+	file := frame.File
+	if slash := strings.LastIndexByte(file, '/'); slash >= 0 {
+		if dir := strings.LastIndexByte(file[:slash], '/'); dir >= 0 {
+			file = file[dir+1:]
+		}
+	}
+	return file + ":" + strconv.Itoa(frame.Line)
+}
@@ -0,0 +1,113 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+var console_regex = regexp.MustCompile(`^(?P<time>\d{2}:\d{2}:\d{2}\.\d{3}) (?P<badge>\S+ *) (?P<message>[^\n]+)\n$`)
+
+func TestConsole(t *testing.T) {
+	t.Run("plain", func(t *testing.T) {
+		format := ConsoleFormat(ConsoleConfig{Color: ColorNever})
+		out := format.FormatAndNormalize(logf.Warning, "test log", logf.NewProps(
+			logf.String("detail", "two words"),
+			logf.Int("count", 2),
+		))
+		matches := console_regex.FindStringSubmatch(out)
+		if matches == nil {
+			t.Fatalf("output didn't match: %q", out)
+		}
+		if badge := matches[2]; badge != "warn  " {
+			t.Errorf("wanted padded badge, got %q", badge)
+		}
+		if msg := matches[3]; msg != `test log detail="two words" count=2` {
+			t.Errorf("wrong message and props: %q", msg)
+		}
+	})
+	t.Run("level names", func(t *testing.T) {
+		format := ConsoleFormat(ConsoleConfig{Color: ColorNever, LevelNames: logf.LetterLevelNames})
+		out := format.FormatAndNormalize(logf.Warning, "test log", logf.NewProps())
+		if matches := console_regex.FindStringSubmatch(out); matches == nil || matches[2] != "W" {
+			t.Errorf("wanted a one-letter badge, got %q", out)
+		}
+	})
+	t.Run("error stack", func(t *testing.T) {
+		format := ConsoleFormat(ConsoleConfig{Color: ColorNever})
+		out := format.FormatAndNormalize(logf.Error, "test log", logf.NewProps(logf.ErrStack(errors.New("failed"))))
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		if len(lines) < 2 || !strings.HasSuffix(lines[0], "error=failed") || !strings.Contains(lines[1], "formats.TestConsole.func") {
+			t.Errorf("wanted the stack under the props, got %q", out)
+		}
+	})
+	t.Run("source", func(t *testing.T) {
+		format := ConsoleFormat(ConsoleConfig{Color: ColorNever})
+		source := logf.Frame{Function: "main.run", File: "/src/app/cmd/main.go", Line: 42}
+		out := format.FormatAndNormalize(logf.Error, "test log", logf.NewProps(
+			logf.Prop{Name: logf.SOURCE, Value: source},
+			logf.String("a", "b"),
+		))
+		if !strings.HasSuffix(out, " test log a=b cmd/main.go:42\n") {
+			t.Errorf("wanted the source after the props, got %q", out)
+		}
+	})
+	t.Run("stack prop", func(t *testing.T) {
+		format := ConsoleFormat(ConsoleConfig{Color: ColorNever})
+		out := format.FormatAndNormalize(logf.Critical, "test log", logf.NewProps(
+			logf.Prop{Name: logf.STACK, Value: []logf.Frame{{Function: "main.run", File: "main.go", Line: 12}}},
+		))
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		if len(lines) != 2 || strings.Contains(lines[0], "stack=") || strings.TrimSpace(lines[1]) != "main.run main.go:12" {
+			t.Errorf("wanted the stack on indented lines, got %q", out)
+		}
+	})
+	t.Run("multiline", func(t *testing.T) {
+		format := ConsoleFormat(ConsoleConfig{Color: ColorNever})
+		out := format.FormatAndNormalize(logf.Error, "first\nsecond", logf.NewProps())
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("wanted 2 lines, got %q", out)
+		}
+		if col := strings.Index(lines[0], "first"); lines[1] != strings.Repeat(" ", col)+"second" {
+			t.Errorf("second line isn't aligned with the first: %q", out)
+		}
+	})
+	t.Run("color", func(t *testing.T) {
+		format := ConsoleFormat(ConsoleConfig{Color: ColorAlways})
+		out := format.FormatAndNormalize(logf.Error, "test log", logf.NewProps(logf.String("detail", "x")))
+		if !strings.Contains(out, consoleLevelColors[logf.Error]+"err") || !strings.Contains(out, ansiDim+"detail=x"+ansiReset) {
+			t.Errorf("wanted colored badge and dimmed props, got %q", out)
+		}
+	})
+	t.Run("no color", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "1")
+		format := ConsoleFormat(ConsoleConfig{})
+		if out := format.FormatAndNormalize(logf.Error, "test log", logf.NewProps()); strings.Contains(out, "\x1b") {
+			t.Errorf("NO_COLOR output has escape codes: %q", out)
+		}
+	})
+	t.Run("not a terminal", func(t *testing.T) {
+		format := ConsoleFormat(ConsoleConfig{Output: &TestWriter{}})
+		if out := format.FormatAndNormalize(logf.Error, "test log", logf.NewProps()); strings.Contains(out, "\x1b") {
+			t.Errorf("non-terminal output has escape codes: %q", out)
+		}
+	})
+}
@@ -0,0 +1,198 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+// ECSFields maps well-known prop names onto their Elastic Common Schema fields.
+// ECSConfig.Fields adds to or overrides these per format.
+// Only names that can't mean anything else are mapped. Generic names, like path, method, url, host,
+// or service, stay under the namespace unless a format maps them with Fields, like
+// Fields: map[string]string{"path": "url.path"}.
+var ECSFields = map[string]string{
+	"error":           "error.message",
+	"error_type":      "error.type",
+	"stack":           "error.stack_trace",
+	"stack_trace":     "error.stack_trace",
+	"http.method":     "http.request.method",
+	"http_method":     "http.request.method",
+	"http.status":     "http.response.status_code",
+	"http_status":     "http.response.status_code",
+	"http.url":        "url.full",
+	"user_agent":      "user_agent.original",
+	"client_ip":       "client.ip",
+	"remote_addr":     "client.address",
+	"trace_id":        "trace.id",
+	"trace.id":        "trace.id",
+	"span_id":         "span.id",
+	"span.id":         "span.id",
+	"transaction_id":  "transaction.id",
+	"service_name":    "service.name",
+	"service.name":    "service.name",
+	"service_version": "service.version",
+	"hostname":        "host.hostname",
+	"user_id":         "user.id",
+	SYSLOG_HOSTNAME:   "host.hostname",
+	SYSLOG_APPNAME:    "service.name",
+}
+
+// ECSConfig sets default values for ECSFormat.
+// Usage notes:
+//   - TimeFormat defaults to time.RFC3339Nano
+//   - Namespace holds props without an ECS mapping, and defaults to labels
+//   - Fields maps additional prop names to ECS fields, and takes precedence over ECSFields
+//   - Version is written as ecs.version, and defaults to 8.11.0
+//   - LevelNames names levels for log.level, instead of the logger's Config.LevelNames
+type ECSConfig struct {
+	TimeFormat string
+	Namespace  string
+	Fields     map[string]string
+	Version    string
+	LevelNames *logf.LevelNames
+}
+
+func (conf ECSConfig) withDefaults() ECSConfig {
+	if conf.TimeFormat == "" {
+		conf.TimeFormat = time.RFC3339Nano
+	}
+	if conf.Namespace == "" {
+		conf.Namespace = "labels"
+	}
+	if conf.Version == "" {
+		conf.Version = "8.11.0"
+	}
+	return conf
+}
+
+// ECSFormat provides Elastic Common Schema JSON, written as nested objects:
+//   - @timestamp is the current time in UTC
+//   - log.level is the level keyword
+//   - message is the log message
+//   - ecs.version is conf.Version
+//   - Props named in conf.Fields or ECSFields are written to their ECS field. When the error prop
+//     holds an error, its type is also written to error.type, and the stack from logf.Err is
+//     written to error.stack_trace.
+//   - The logf.SOURCE frame from Config.AddCaller is written to log.origin
+//   - Props in a logf.Group are matched to fields by their dotted name, like http.method
+//   - Other props are written under conf.Namespace. ECS doesn't allow dots in label names, so
+//     they're replaced with _. ECS labels only hold keywords, so under the default labels values
+//     are written as strings, like logfmt writes them; a custom Namespace keeps them structured.
+func ECSFormat(conf ECSConfig) logf.Formatter {
+	conf = conf.withDefaults()
+	fields := make(map[string]string, len(ECSFields)+len(conf.Fields))
+	for name, field := range ECSFields {
+		fields[name] = field
+	}
+	for name, field := range conf.Fields {
+		fields[name] = field
+	}
+	return func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		record := map[string]any{}
+		if source, ok := props.Get(logf.SOURCE).(logf.Frame); ok {
+			setECSField(record, "log.origin.file.name", source.File)
+			setECSField(record, "log.origin.file.line", source.Line)
+			setECSField(record, "log.origin.function", source.Function)
+			props = props.Without(logf.SOURCE)
+		}
+		for _, prop := range flattenGroups(props.Slice()) {
+			field, ok := fields[prop.Name]
+			if !ok {
+				name := conf.Namespace + "." + strings.ReplaceAll(prop.Name, ".", "_")
+				if conf.Namespace == "labels" {
+					setECSField(record, name, textValue(prop.Value))
+				} else {
+					setECSField(record, name, ecsValue(prop.Value))
+				}
+				continue
+			}
+			if errValue, isErrValue := prop.Value.(*logf.ErrorValue); isErrValue && errValue != nil && field == "error.message" {
+				setECSField(record, "error.message", errValue.Message)
+				setECSField(record, "error.type", errValue.Type)
+				if stack := StackTrace(errorStack(errValue)); stack != "" {
+					setECSField(record, "error.stack_trace", stack)
+				}
+				continue
+			}
+			if frames, isStack := prop.Value.([]logf.Frame); isStack {
+				setECSField(record, field, StackTrace(frames))
+				continue
+			}
+			if err, isErr := prop.Value.(error); isErr && !nilPointer(err) && field == "error.message" {
+				setECSField(record, "error.message", err.Error())
+				setECSField(record, "error.type", fmt.Sprintf("%T", err))
+				continue
+			}
+			setECSField(record, field, ecsValue(prop.Value))
+		}
+		record["@timestamp"] = time.Now().UTC().Format(conf.TimeFormat)
+		record["message"] = msg
+		setECSField(record, "log.level", levelName(conf.LevelNames, level, props))
+		setECSField(record, "ecs.version", conf.Version)
+
+		raw, _ := json.Marshal(record)
+		return string(raw)
+	}
+}
+
+// ecsValue encodes a prop value the same way as the other JSON formats.
+func ecsValue(value any) json.RawMessage {
+	return appendJSON(nil, normalize(value))
+}
+
+// setECSField sets a dotted field path in record, creating objects along the way.
+// If a path crosses a field that's already set to a value, the value is replaced with an object.
+func setECSField(record map[string]any, path string, value any) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := record[part].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			record[part] = next
+		}
+		record = next
+	}
+	record[parts[len(parts)-1]] = value
+}
+
+// StackTrace formats frames like a Go panic: each function, then its file and line on an indented
+// line.
+func StackTrace(frames []logf.Frame) string {
+	var out strings.Builder
+	for _, frame := range frames {
+		out.WriteString(frame.Function)
+		out.WriteString("\n\t")
+		out.WriteString(frame.String())
+		out.WriteByte('\n')
+	}
+	return out.String()
+}
+
+// errorStack returns the deepest stack in value's causes, which is the closest to where the error
+// happened, or value's own stack.
+func errorStack(value *logf.ErrorValue) []logf.Frame {
+	for _, cause := range value.Causes {
+		if stack := errorStack(cause); stack != nil {
+			return stack
+		}
+	}
+	return value.Stack
+}
@@ -0,0 +1,161 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+// lookupPath gets a dotted path from decoded JSON objects.
+func lookupPath(record map[string]any, path ...string) any {
+	var value any = record
+	for _, part := range path {
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = obj[part]
+	}
+	return value
+}
+
+func TestECS(t *testing.T) {
+	format := ECSFormat(ECSConfig{
+		Namespace: "app",
+		Fields:    map[string]string{"tenant": "organization.id"},
+	})
+	out := format.FormatAndNormalize(logf.Error, "request failed", logf.NewProps(
+		logf.String("http.method", "GET"),
+		logf.Int("http_status", 502),
+		logf.String("trace_id", "abc123"),
+		logf.String("tenant", "acme"),
+		logf.String("cache.hit", "false"),
+		logf.Prop{Name: "error", Value: errors.New("upstream timed out")},
+	))
+	record := map[string]any{}
+	if err := json.Unmarshal([]byte(out), &record); err != nil {
+		t.Fatal(err, out)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, record["@timestamp"].(string)); err != nil {
+		t.Errorf("bad @timestamp: %v", err)
+	}
+	wanted := map[string]any{
+		"message":                   "request failed",
+		"log.level":                 "err",
+		"ecs.version":               "8.11.0",
+		"http.request.method":       "GET",
+		"http.response.status_code": float64(502),
+		"trace.id":                  "abc123",
+		"organization.id":           "acme",
+		"error.message":             "upstream timed out",
+		"error.type":                "*errors.errorString",
+	}
+	for path, v := range wanted {
+		if got := lookupPath(record, strings.Split(path, ".")...); got != v {
+			t.Errorf("wanted %s=%v, got %v", path, v, got)
+		}
+	}
+	if got := lookupPath(record, "app", "cache_hit"); got != "false" {
+		t.Errorf("wanted unmapped prop under namespace, got %v", got)
+	}
+}
+
+func TestECSGenericNames(t *testing.T) {
+	props := func() *logf.Props {
+		return logf.NewProps(logf.String("path", "/etc/app.yaml"), logf.String("host", "db1"), logf.String("method", "rsync"))
+	}
+	record := map[string]any{}
+	json.Unmarshal([]byte(ECSFormat(ECSConfig{}).FormatAndNormalize(logf.Informational, "msg", props())), &record)
+	for _, name := range []string{"path", "host", "method"} {
+		if lookupPath(record, "labels", name) == nil {
+			t.Errorf("generic prop %s wasn't written as a label: %v", name, record)
+		}
+	}
+	if record["url"] != nil || record["host"] != nil || record["http"] != nil {
+		t.Errorf("generic props were mapped to ECS fields: %v", record)
+	}
+
+	record = map[string]any{}
+	json.Unmarshal([]byte(ECSFormat(ECSConfig{Fields: map[string]string{"path": "url.path"}}).FormatAndNormalize(logf.Informational, "msg", props())), &record)
+	if got := lookupPath(record, "url", "path"); got != "/etc/app.yaml" {
+		t.Errorf("Fields didn't map path: %v", record)
+	}
+}
+
+func TestECSErr(t *testing.T) {
+	format := ECSFormat(ECSConfig{})
+	out := format.FormatAndNormalize(logf.Error, "request failed", logf.NewProps(
+		logf.ErrStack(fmt.Errorf("calling upstream: %w", os.ErrDeadlineExceeded)),
+		logf.Prop{Name: logf.SOURCE, Value: logf.Frame{Function: "main.run", File: "main.go", Line: 42}},
+	))
+	record := map[string]any{}
+	if err := json.Unmarshal([]byte(out), &record); err != nil {
+		t.Fatal(err, out)
+	}
+	if got := lookupPath(record, "error", "message"); got != "calling upstream: i/o timeout" {
+		t.Errorf("wrong error.message: %v", got)
+	}
+	if got := lookupPath(record, "error", "type"); got != "*fmt.wrapError" {
+		t.Errorf("wrong error.type: %v", got)
+	}
+	if file, line := lookupPath(record, "log", "origin", "file", "name"), lookupPath(record, "log", "origin", "file", "line"); file != "main.go" || line != float64(42) {
+		t.Errorf("wrong log.origin: %v:%v", file, line)
+	}
+	if stack, _ := lookupPath(record, "error", "stack_trace").(string); !strings.HasPrefix(stack, "github.com/decentplatforms/appkit/logf/formats.TestECSErr\n\t") {
+		t.Errorf("wrong error.stack_trace: %q", stack)
+	}
+}
+
+func TestECSLabels(t *testing.T) {
+	props := func() *logf.Props {
+		return logf.NewProps(
+			logf.Int("attempt", 3),
+			logf.Strings("tags", []string{"a", "b"}),
+			logf.Group("http", logf.String("method", "GET"), logf.Group("cache", logf.Bool("hit", true))),
+		)
+	}
+	labels := map[string]any{}
+	json.Unmarshal([]byte(ECSFormat(ECSConfig{}).FormatAndNormalize(logf.Informational, "msg", props())), &labels)
+	wantedLabels := map[string]any{
+		"labels.attempt":        "3",
+		"labels.tags":           `["a","b"]`,
+		"labels.http_cache_hit": "true",
+		"http.request.method":   "GET",
+	}
+	custom := map[string]any{}
+	json.Unmarshal([]byte(ECSFormat(ECSConfig{Namespace: "app"}).FormatAndNormalize(logf.Informational, "msg", props())), &custom)
+	wantedCustom := map[string]any{
+		"app.attempt":        float64(3),
+		"app.http_cache_hit": true,
+	}
+	for path, v := range wantedLabels {
+		if got := lookupPath(labels, strings.Split(path, ".")...); got != v {
+			t.Errorf("labels: wanted %s=%v, got %v", path, v, got)
+		}
+	}
+	for path, v := range wantedCustom {
+		if got := lookupPath(custom, strings.Split(path, ".")...); got != v {
+			t.Errorf("custom: wanted %s=%v, got %v", path, v, got)
+		}
+	}
+}
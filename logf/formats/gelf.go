@@ -0,0 +1,141 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+// GELFConfig sets default values for GELFFormat.
+// Usage notes:
+//   - Host defaults to the machine's hostname at format creation; the SYSLOG_HOSTNAME prop overrides it
+//   - ShortMessageLength defaults to 250
+type GELFConfig struct {
+	Host               string
+	ShortMessageLength int
+}
+
+func (conf GELFConfig) withDefaults() GELFConfig {
+	if conf.Host == "" {
+		oshost, err := os.Hostname()
+		if err != nil {
+			conf.Host = "localhost"
+		} else {
+			conf.Host = oshost
+		}
+	}
+	if conf.ShortMessageLength <= 0 {
+		conf.ShortMessageLength = 250
+	}
+	return conf
+}
+
+// GELFFormat provides Graylog Extended Log Format (GELF 1.1) JSON, for use with output.GELFUDP
+// or output.GELFTCP.
+//   - level is the log level, which maps directly since both follow syslog severity
+//   - short_message is the first line of the message, cut to conf.ShortMessageLength bytes without
+//     splitting a character. GELF requires it, so a blank first line is sent as "(empty)".
+//   - full_message is the whole message, sent only if it's multi-line or longer than short_message
+//   - timestamp is the SYSLOG_TIMESTAMP prop or the current time, in seconds since the epoch with
+//     millisecond precision
+//   - Props become additional fields named _<name>. Characters GELF doesn't allow in field names
+//     are replaced with _, and a prop named id is sent as __id since _id is reserved.
+//     Numbers are sent as numbers, and everything else as strings.
+//   - Error props from logf.Err are sent as their message, with the error's type and stack in
+//     _<name>_type and _<name>_stack
+func GELFFormat(conf GELFConfig) logf.Formatter {
+	conf = conf.withDefaults()
+	return func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		record := make(map[string]any, 6+props.Len())
+		for _, prop := range props.Without(syslogHeaders...).Slice() {
+			name := GELFFieldName(prop.Name)
+			if errValue, ok := prop.Value.(*logf.ErrorValue); ok && errValue != nil {
+				record[name+"_type"] = errValue.Type
+				if stack := StackTrace(errorStack(errValue)); stack != "" {
+					record[name+"_stack"] = stack
+				}
+			}
+			record[name] = gelfValue(prop.Value)
+		}
+
+		host, ok := props.Get(SYSLOG_HOSTNAME).(string)
+		if !ok {
+			host = conf.Host
+		}
+		short, _, multiline := strings.Cut(msg, "\n")
+		if len(short) > conf.ShortMessageLength {
+			cut := conf.ShortMessageLength
+			for cut > 0 && !utf8.RuneStart(short[cut]) {
+				cut--
+			}
+			short = short[:cut]
+		}
+		if multiline || len(short) < len(msg) {
+			record["full_message"] = msg
+		}
+		if strings.TrimSpace(short) == "" {
+			short = gelfEmptyMessage
+		}
+		gelfLevel := int(level)
+		if gelfLevel < int(logf.MOST_SEVERE) {
+			gelfLevel = int(logf.MOST_SEVERE)
+		} else if gelfLevel > int(logf.LEAST_SEVERE) {
+			gelfLevel = int(logf.LEAST_SEVERE)
+		}
+
+		record["version"] = "1.1"
+		record["host"] = host
+		record["short_message"] = short
+		record["timestamp"] = float64(syslogTime(props).UnixMilli()) / 1000
+		record["level"] = gelfLevel
+
+		raw, _ := json.Marshal(record)
+		return string(raw)
+	}
+}
+
+// gelfEmptyMessage is the short_message for messages whose first line is blank.
+const gelfEmptyMessage = "(empty)"
+
+// GELFFieldName converts a prop name into a GELF additional field name.
+// The reserved _id is never returned: a prop named id is sent as __id.
+func GELFFieldName(name string) string {
+	field := []byte(name)
+	for i, c := range field {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-') {
+			field[i] = '_'
+		}
+	}
+	if string(field) == "id" {
+		return "__id"
+	}
+	return "_" + string(field)
+}
+
+func gelfValue(value any) any {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v
+	case string:
+		return v
+	default:
+		return textValue(v)
+	}
+}
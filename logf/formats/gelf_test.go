@@ -0,0 +1,62 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/decentplatforms/appkit/logf"
+	"github.com/decentplatforms/appkit/logf/testhelp"
+)
+
+func TestGELFFormat(t *testing.T) {
+	format := GELFFormat(GELFConfig{Host: "test-host", ShortMessageLength: 4})
+	record := func(msg string, props ...logf.Prop) map[string]any {
+		out := map[string]any{}
+		if err := json.Unmarshal([]byte(format.FormatAndNormalize(logf.Warning, msg, logf.NewProps(props...))), &out); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	empty := record("")
+	blank := record("\nsecond line")
+	cut := record("hé€o")
+	ids := record("ids", logf.String("id", "a"), logf.String("i d", "b"), logf.Int("n", 1))
+	res := testhelp.ResultsMap{
+		"empty":      fmt.Sprint(empty["short_message"]),
+		"empty_full": fmt.Sprint(empty["full_message"]),
+		"blank":      fmt.Sprint(blank["short_message"]),
+		"cut":        fmt.Sprint(cut["short_message"]),
+		"cut_full":   fmt.Sprint(cut["full_message"]),
+		"id":         fmt.Sprintln(ids["__id"], ids["_id"]),
+		"sanitized":  fmt.Sprint(ids["_i_d"]),
+		"number":     fmt.Sprint(ids["_n"]),
+	}
+	wanted := testhelp.ResultsMap{
+		"empty":      "(empty)",
+		"empty_full": "<nil>",
+		"blank":      "(empty)",
+		"cut":        "hé",
+		"cut_full":   "hé€o",
+		"id":         "a <nil>\n",
+		"sanitized":  "b",
+		"number":     "1",
+	}
+	if err := testhelp.ValidateResults(res, wanted); err != nil {
+		t.Error(err)
+	}
+}
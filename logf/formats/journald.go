@@ -0,0 +1,121 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+// JournaldConfig sets default values for JournaldFormat.
+// Usage notes:
+//   - Identifier is sent as SYSLOG_IDENTIFIER, and defaults to the executable name
+//   - The SYSLOG_APPNAME prop overrides Identifier
+//   - Facility is sent as SYSLOG_FACILITY if it's not 0. The SYSLOG_FACILITY prop overrides it.
+type JournaldConfig struct {
+	Identifier string
+	Facility   int
+}
+
+func (conf JournaldConfig) withDefaults() JournaldConfig {
+	if conf.Identifier == "" {
+		conf.Identifier = filepath.Base(os.Args[0])
+	}
+	return conf
+}
+
+// JournaldFormat provides the systemd journal native protocol, for use with output.Journal.
+//   - MESSAGE is the log message
+//   - PRIORITY is the log level, which maps directly since both follow syslog severity
+//   - Props become fields. Names are uppercased, characters other than A-Z, 0-9 and _ are replaced
+//     with _, leading underscores and digits are dropped, and names are cut to 64 characters.
+//     Props whose names are empty after that are skipped.
+//   - Values containing newlines use the protocol's binary length-prefixed encoding.
+//
+// PRIORITY is always the last field, so whitespace normalization can't change any field values.
+func JournaldFormat(conf JournaldConfig) logf.Formatter {
+	conf = conf.withDefaults()
+	return func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		var out strings.Builder
+		writeJournalField(&out, "MESSAGE", msg)
+
+		identifier, ok := props.Get(SYSLOG_APPNAME).(string)
+		if !ok {
+			identifier = conf.Identifier
+		}
+		writeJournalField(&out, "SYSLOG_IDENTIFIER", identifier)
+		facility := syslogFacility(props, -1)
+		if facility < 0 && conf.Facility != 0 {
+			facility = conf.Facility
+		}
+		if facility >= 0 {
+			writeJournalField(&out, "SYSLOG_FACILITY", strconv.Itoa(facility))
+		}
+
+		for _, prop := range props.Without(syslogHeaders...).Slice() {
+			name := JournalFieldName(prop.Name)
+			if name == "" {
+				continue
+			}
+			writeJournalField(&out, name, textValue(prop.Value))
+		}
+
+		priority := int(level)
+		if priority < int(logf.MOST_SEVERE) {
+			priority = int(logf.MOST_SEVERE)
+		} else if priority > int(logf.LEAST_SEVERE) {
+			priority = int(logf.LEAST_SEVERE)
+		}
+		writeJournalField(&out, "PRIORITY", strconv.Itoa(priority))
+		return out.String()
+	}
+}
+
+// JournalFieldName converts a prop name into a valid journal field name.
+// It returns "" if nothing valid is left.
+func JournalFieldName(name string) string {
+	field := []byte(strings.ToUpper(name))
+	for i, c := range field {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			field[i] = '_'
+		}
+	}
+	name = strings.TrimLeft(string(field), "_0123456789")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+func writeJournalField(out *strings.Builder, name, value string) {
+	out.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		out.WriteByte('=')
+		out.WriteString(value)
+		out.WriteByte('\n')
+		return
+	}
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	out.WriteByte('\n')
+	out.Write(size[:])
+	out.WriteString(value)
+	out.WriteByte('\n')
+}
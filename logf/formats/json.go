@@ -15,24 +15,34 @@
 package formats
 
 import (
+	"bytes"
 	"encoding/json"
 	"time"
 
 	"github.com/decentplatforms/appkit/logf"
 )
 
-type jsonLog struct {
-	Level     logf.LogLevel  `json:"level"`
-	LevelStr  string         `json:"level_str"`
-	Timestamp string         `json:"timestamp"`
-	Message   string         `json:"message"`
-	Props     map[string]any `json:"props,omitempty"`
-}
-
+// JSONConfig sets default values for JSONFormat and JSONPrettyFormat.
+// Usage notes:
+//   - TimeFormat defaults to time.RFC3339
+//   - Prefix and Indent are only used by JSONPrettyFormat. Indent defaults to a tab.
+//   - LevelKey, LevelStrKey, TimestampKey, MessageKey, and PropsKey rename the built-in keys,
+//     which default to level, level_str, timestamp, message, and props. Set a key to "-" to leave
+//     that field out.
+//   - Flatten writes props in the top-level object instead of under PropsKey. Props whose names
+//     collide with a built-in key are prefixed with _.
+//   - LevelNames names levels for level_str, instead of the logger's Config.LevelNames
 type JSONConfig struct {
-	TimeFormat string
-	Prefix     string
-	Indent     string
+	TimeFormat   string
+	Prefix       string
+	Indent       string
+	LevelKey     string
+	LevelStrKey  string
+	TimestampKey string
+	MessageKey   string
+	PropsKey     string
+	Flatten      bool
+	LevelNames   *logf.LevelNames
 }
 
 func (conf JSONConfig) withDefaults() JSONConfig {
@@ -42,31 +52,97 @@ func (conf JSONConfig) withDefaults() JSONConfig {
 	if conf.Indent == "" {
 		conf.Indent = "\t"
 	}
+	if conf.LevelKey == "" {
+		conf.LevelKey = "level"
+	}
+	if conf.LevelStrKey == "" {
+		conf.LevelStrKey = "level_str"
+	}
+	if conf.TimestampKey == "" {
+		conf.TimestampKey = "timestamp"
+	}
+	if conf.MessageKey == "" {
+		conf.MessageKey = "message"
+	}
+	if conf.PropsKey == "" {
+		conf.PropsKey = "props"
+	}
 	return conf
 }
 
+// JSONFormat provides one JSON object per line, written in a fixed order:
+//   - level is the level number, and level_str is the level keyword
+//   - timestamp is the current time in UTC
+//   - message is the log message
+//   - props holds the props in the order they were added, and is left out if there are none
+//
+// Prop values are encoded the same way for every structured format: times are RFC 3339 strings
+// in UTC, durations, errors, and fmt.Stringers are strings, and []byte is base64.
 func JSONFormat(conf JSONConfig) logf.Formatter {
-	return func(level logf.LogLevel, msg string, props *logf.Props) string {
-		raw, _ := json.Marshal(jsonLog{
-			Level:     level,
-			LevelStr:  level.String(),
-			Timestamp: time.Now().UTC().Format(conf.TimeFormat),
-			Message:   msg,
-			Props:     props.Map(),
-		})
-		return string(raw)
+	conf = conf.withDefaults()
+	return func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		return string(appendJSON(nil, jsonRecord(conf, level, msg, props)))
 	}
 }
 
+// JSONPrettyFormat provides the same objects as JSONFormat, indented with conf.Prefix and conf.Indent.
 func JSONPrettyFormat(conf JSONConfig) logf.Formatter {
-	return func(level logf.LogLevel, msg string, props *logf.Props) string {
-		raw, _ := json.MarshalIndent(jsonLog{
-			Level:     level,
-			LevelStr:  level.String(),
-			Timestamp: time.Now().UTC().Format(conf.TimeFormat),
-			Message:   msg,
-			Props:     props.Map(),
-		}, conf.Prefix, conf.Indent)
-		return string(raw)
+	conf = conf.withDefaults()
+	return func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		var out bytes.Buffer
+		json.Indent(&out, appendJSON(nil, jsonRecord(conf, level, msg, props)), conf.Prefix, conf.Indent)
+		return out.String()
+	}
+}
+
+// levelName returns the name of level from names, or from the logger's names if names is nil.
+func levelName(names *logf.LevelNames, level logf.LogLevel, props logf.PropsView) string {
+	if names != nil {
+		return names.Name(level)
+	}
+	return props.LevelName(level)
+}
+
+// jsonRecord returns the record written by JSONFormat.
+// extra fields are written after the message, and props can't replace them when flattened.
+func jsonRecord(conf JSONConfig, level logf.LogLevel, msg string, props logf.PropsView, extra ...field) object {
+	record := make(object, 0, 5+len(extra))
+	add := func(key string, value any) {
+		if key != "-" {
+			record = append(record, field{name: key, value: value})
+		}
+	}
+	add(conf.LevelKey, int64(level))
+	add(conf.LevelStrKey, levelName(conf.LevelNames, level, props))
+	add(conf.TimestampKey, time.Now().UTC().Format(conf.TimeFormat))
+	add(conf.MessageKey, msg)
+	record = append(record, extra...)
+	if props.Len() == 0 {
+		return record
+	}
+
+	propsObj := normalize(props.Slice()).(object)
+	if !conf.Flatten {
+		add(conf.PropsKey, propsObj)
+		return record
+	}
+	builtin := make(map[string]bool, len(record))
+	taken := make(map[string]bool, len(record)+len(propsObj))
+	for _, f := range record {
+		builtin[f.name] = true
+		taken[f.name] = true
+	}
+	for _, f := range propsObj {
+		taken[f.name] = true
+	}
+	for _, f := range propsObj {
+		if builtin[f.name] {
+			for taken[f.name] {
+				f.name = "_" + f.name
+			}
+			taken[f.name] = true
+		}
+		record = append(record, f)
 	}
+	return record
 }
@@ -0,0 +1,305 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+	"github.com/decentplatforms/appkit/logf/testhelp"
+)
+
+type jsonTestStruct struct {
+	A int    `json:"a"`
+	B string `json:"b,omitempty"`
+}
+
+func TestJSONValues(t *testing.T) {
+	when := time.Date(2023, 10, 1, 12, 30, 0, 500, time.FixedZone("test", 3600))
+	tests := map[string]any{
+		"string":   "a \"quoted\"\n<value>\u2028",
+		"invalid":  "bad\xffutf8",
+		"int":      int8(-3),
+		"uint":     uint32(7),
+		"float":    float32(1.5),
+		"big":      1e21,
+		"nan":      math.NaN(),
+		"inf":      math.Inf(-1),
+		"bool":     true,
+		"nil":      nil,
+		"time":     when,
+		"duration": 1500 * time.Millisecond,
+		"error":    errors.New("failed"),
+		"bytes":    []byte("hi"),
+		"stringer": net.IPv4(127, 0, 0, 1),
+		"level":    logf.Warning,
+		"slice":    []any{1, "two", []string{"three"}},
+		"map":      map[string]int{"b": 2, "a": 1},
+		"props":    []logf.Prop{logf.String("z", "last"), logf.Int("a", 1)},
+		"struct":   jsonTestStruct{A: 1},
+		"pointer":  &jsonTestStruct{B: "x"},
+		"nilptr":   (*jsonTestStruct)(nil),
+	}
+	expects := testhelp.ResultsMap{
+		"string":   `"a \"quoted\"\n<value>\u2028"`,
+		"invalid":  `"bad\ufffdutf8"`,
+		"int":      `-3`,
+		"uint":     `7`,
+		"float":    `1.5`,
+		"big":      `1e+21`,
+		"nan":      `"NaN"`,
+		"inf":      `"-Inf"`,
+		"bool":     `true`,
+		"nil":      `null`,
+		"time":     `"2023-10-01T11:30:00.0000005Z"`,
+		"duration": `"1.5s"`,
+		"error":    `"failed"`,
+		"bytes":    `"aGk="`,
+		"stringer": `"127.0.0.1"`,
+		"level":    `"warn"`,
+		"slice":    `[1,"two",["three"]]`,
+		"map":      `{"a":1,"b":2}`,
+		"props":    `{"z":"last","a":1}`,
+		"struct":   `{"a":1}`,
+		"pointer":  `{"a":0,"b":"x"}`,
+		"nilptr":   `null`,
+	}
+	res := testhelp.ResultsMap{}
+	for name, value := range tests {
+		raw := appendJSON(nil, normalize(value))
+		if !json.Valid(raw) {
+			t.Errorf("%s: invalid JSON %s", name, raw)
+		}
+		res[name] = string(raw)
+	}
+	if err := testhelp.ValidateResults(res, expects); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	props := func() *logf.Props {
+		return logf.NewProps(
+			logf.String("zeta", "z"),
+			logf.String("message", "collides"),
+			logf.String("_message", "also collides"),
+			logf.Int("alpha", 1),
+		)
+	}
+	tests := map[string]JSONConfig{
+		"default": {},
+		"flatten": {Flatten: true},
+		"renamed": {LevelKey: "severity", LevelStrKey: "-", TimestampKey: "-", MessageKey: "msg", PropsKey: "fields"},
+		"pretty":  {Indent: " "},
+	}
+	expects := testhelp.ResultsMap{
+		"default": `{"level":4,"level_str":"warn","message":"test log","props":{"zeta":"z","message":"collides","_message":"also collides","alpha":1}}`,
+		"flatten": `{"level":4,"level_str":"warn","message":"test log","zeta":"z","__message":"collides","_message":"also collides","alpha":1}`,
+		"renamed": `{"severity":4,"msg":"test log","fields":{"zeta":"z","message":"collides","_message":"also collides","alpha":1}}`,
+		"pretty":  "{\n \"level\": 4,\n \"level_str\": \"warn\",\n \"message\": \"test log\",\n \"props\": {\n  \"zeta\": \"z\",\n  \"message\": \"collides\",\n  \"_message\": \"also collides\",\n  \"alpha\": 1\n }\n}\n",
+	}
+	res := testhelp.ResultsMap{}
+	for name, conf := range tests {
+		// Timestamps aren't deterministic, so they're left out.
+		conf.TimestampKey = "-"
+		format := JSONFormat(conf)
+		if name == "pretty" {
+			format = JSONPrettyFormat(conf)
+		}
+		out := format.FormatAndNormalize(logf.Warning, "test log", props())
+		if name != "pretty" {
+			out = strings.TrimSuffix(out, "\n")
+		}
+		res[name] = out
+	}
+	if err := testhelp.ValidateResults(res, expects); err != nil {
+		t.Error(err)
+	}
+
+	out := JSONFormat(JSONConfig{TimestampKey: "-"}).FormatAndNormalize(logf.Error, "test log", logf.NewProps(
+		logf.Err(fmt.Errorf("saving: %w", errors.Join(errors.New("disk full"), errors.New("retry failed")))),
+	))
+	wantedErr := `{"level":3,"level_str":"err","message":"test log","props":{"error":{"message":"saving: disk full\nretry failed","type":"*fmt.wrapError",` +
+		`"causes":[{"message":"disk full\nretry failed","type":"*errors.joinError","causes":[{"message":"disk full","type":"*errors.errorString"},{"message":"retry failed","type":"*errors.errorString"}]}]}}}` + "\n"
+	if out != wantedErr {
+		t.Errorf("wrong error prop:\n got %s\nwant %s", out, wantedErr)
+	}
+
+	out = JSONFormat(JSONConfig{TimestampKey: "-"}).FormatAndNormalize(logf.Critical, "test log", logf.NewProps(
+		logf.Prop{Name: logf.STACK, Value: []logf.Frame{{Function: "main.run", File: "main.go", Line: 12}, {Function: "main.main", File: "main.go", Line: 5}}},
+	))
+	wantedStack := `{"level":2,"level_str":"crit","message":"test log","props":{"stack":[{"function":"main.run","file":"main.go","line":12},{"function":"main.main","file":"main.go","line":5}]}}` + "\n"
+	if out != wantedStack {
+		t.Errorf("wrong stack prop:\n got %s\nwant %s", out, wantedStack)
+	}
+
+	out = JSONFormat(JSONConfig{}).FormatAndNormalize(logf.Warning, "test log", logf.NewProps())
+	if strings.Contains(out, `"props"`) || !strings.Contains(out, `"timestamp":"`) {
+		t.Errorf("wrong output without props: %s", out)
+	}
+}
+
+type testAddress struct {
+	City string
+	Zip  int
+}
+
+func (addr testAddress) MarshalProps() []logf.Prop {
+	return []logf.Prop{logf.String("city", addr.City), logf.Int("zip", addr.Zip)}
+}
+
+func TestTypedProps(t *testing.T) {
+	props := func() *logf.Props {
+		return logf.NewProps(
+			logf.Time("at", time.Date(2024, 5, 6, 7, 8, 9, 0, time.FixedZone("x", 3600))),
+			logf.Duration("took", 1500*time.Millisecond),
+			logf.Bytes("raw", []byte("hi")),
+			logf.Strings("tags", []string{"a", "b c"}),
+			logf.Ints("codes", []int32{200, 404}),
+			logf.Object("addr", testAddress{City: "Oslo", Zip: 150}),
+			logf.Any("small", int8(-3)),
+		)
+	}
+	otel := OTelFormat(OTelConfig{}).FormatAndNormalize(logf.Informational, "test log", props())
+	res := testhelp.ResultsMap{
+		"json":   JSONFormat(JSONConfig{TimestampKey: "-"}).FormatAndNormalize(logf.Informational, "test log", props()),
+		"logfmt": KVFormat(KVConfig{Logfmt: true}).FormatAndNormalize(logf.Informational, "test log", props()),
+		"otel":   otel[strings.Index(otel, `"attributes"`):strings.Index(otel, `]}]}]}`)],
+	}
+	// The logfmt timestamp varies, so only the props are compared.
+	res["logfmt"] = res["logfmt"][strings.Index(res["logfmt"], " at="):]
+	wanted := testhelp.ResultsMap{
+		"json": `{"level":6,"level_str":"info","message":"test log","props":{"at":"2024-05-06T06:08:09Z","took":"1.5s","raw":"aGk=",` +
+			`"tags":["a","b c"],"codes":[200,404],"addr":{"city":"Oslo","zip":150},"small":-3}}` + "\n",
+		"logfmt": ` at=2024-05-06T06:08:09Z took=1.5s raw="aGk=" tags="[\"a\",\"b c\"]" codes=[200,404] addr="{\"city\":\"Oslo\",\"zip\":150}" small=-3` + "\n",
+		"otel": `"attributes":[{"key":"at","value":{"stringValue":"2024-05-06T06:08:09Z"}},{"key":"took","value":{"stringValue":"1.5s"}},` +
+			`{"key":"raw","value":{"bytesValue":"aGk="}},` +
+			`{"key":"tags","value":{"arrayValue":{"values":[{"stringValue":"a"},{"stringValue":"b c"}]}}},` +
+			`{"key":"codes","value":{"arrayValue":{"values":[{"intValue":"200"},{"intValue":"404"}]}}},` +
+			`{"key":"addr","value":{"kvlistValue":{"values":[{"key":"city","value":{"stringValue":"Oslo"}},{"key":"zip","value":{"intValue":"150"}}]}}},` +
+			`{"key":"small","value":{"intValue":"-3"}}`,
+	}
+	if err := testhelp.ValidateResults(res, wanted); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	props := func() *logf.Props {
+		return logf.NewProps(
+			logf.String("id", "a1"),
+			logf.Group("http", logf.String("method", "GET"), logf.Int("status", 200),
+				logf.Group("client", logf.String("ip", "10.0.0.1"))),
+			logf.Group("db", logf.String("query", `say "]"`)),
+		)
+	}
+	syslog := Syslog5424Format(SyslogConfig{Hostname: "host", AppName: "app", ProcID: "1"}).
+		FormatAndNormalize(logf.Informational, "test log", props())
+	res := testhelp.ResultsMap{
+		"json":   JSONFormat(JSONConfig{TimestampKey: "-"}).FormatAndNormalize(logf.Informational, "test log", props()),
+		"logfmt": KVFormat(KVConfig{Logfmt: true}).FormatAndNormalize(logf.Informational, "test log", props()),
+		"5424":   syslog[strings.Index(syslog, " log "):],
+	}
+	res["logfmt"] = res["logfmt"][strings.Index(res["logfmt"], " message="):]
+	wanted := testhelp.ResultsMap{
+		"json": `{"level":6,"level_str":"info","message":"test log","props":{"id":"a1",` +
+			`"http":{"method":"GET","status":200,"client":{"ip":"10.0.0.1"}},"db":{"query":"say \"]\""}}}` + "\n",
+		"logfmt": ` message="test log" id=a1 http.method=GET http.status=200 http.client.ip=10.0.0.1 db.query="say \"]\""` + "\n",
+		"5424":   ` log [http method="GET" status="200" client.ip="10.0.0.1"][db query="say \"\]\""] test log id="a1"` + "\n",
+	}
+	if err := testhelp.ValidateResults(res, wanted); err != nil {
+		t.Error(err)
+	}
+
+	syslog = Syslog5424Format(SyslogConfig{EnterpriseID: "32473", WithProps: SyslogIgnore}).
+		FormatAndNormalize(logf.Informational, "test log", logf.NewProps(
+			logf.Group("a very long group name with spaces", logf.String("k", "1")),
+			logf.Group("http", logf.String("x", "1")),
+			logf.Group("http", logf.String("y", "2")),
+		))
+	if !strings.HasSuffix(syslog, ` [a_very_long_group_name_wit@32473 k="1"][http@32473 x="1" y="2"] test log`+"\n") {
+		t.Errorf("wrong structured data: %q", syslog)
+	}
+}
+
+func TestJSONDuplicateProps(t *testing.T) {
+	props := logf.NewProps(logf.String("a", "1"), logf.String("b", "x"), logf.String("a", "2"))
+	props.Set(logf.String("b", "y"))
+	out := JSONFormat(JSONConfig{TimestampKey: "-"}).FormatAndNormalize(logf.Warning, "test log", props)
+	wanted := `{"level":4,"level_str":"warn","message":"test log","props":{"a":"2","b":"y"}}` + "\n"
+	if out != wanted {
+		t.Errorf("wrong duplicate props:\n got %s\nwant %s", out, wanted)
+	}
+}
+
+func TestJSONCycles(t *testing.T) {
+	self := map[string]any{}
+	self["self"] = self
+	list := []any{nil}
+	list[0] = list
+	type node struct{ Next *node }
+	loop := &node{}
+	loop.Next = loop
+	for name, value := range map[string]any{"map": self, "slice": list, "pointer": loop} {
+		raw := appendJSON(nil, normalize(value))
+		if !json.Valid(raw) {
+			t.Errorf("%s: invalid JSON %s", name, raw)
+		}
+	}
+	if raw := string(appendJSON(nil, normalize(self))); !strings.Contains(raw, `"`+maxDepthValue+`"`) {
+		t.Errorf("wanted a placeholder at the depth limit, got %.100s", raw)
+	}
+}
+
+type nilStringer struct{ name string }
+
+func (s *nilStringer) String() string { return s.name }
+
+type nilError struct{ msg string }
+
+func (err *nilError) Error() string { return err.msg }
+
+func TestTypedNils(t *testing.T) {
+	var err error = (*nilError)(nil)
+	props := func() *logf.Props {
+		return logf.NewProps(logf.Prop{Name: "stringer", Value: (*nilStringer)(nil)}, logf.Prop{Name: "err", Value: err})
+	}
+	out := JSONFormat(JSONConfig{TimestampKey: "-"}).FormatAndNormalize(logf.Warning, "test log", props())
+	wanted := `{"level":4,"level_str":"warn","message":"test log","props":{"stringer":null,"err":null}}` + "\n"
+	if out != wanted {
+		t.Errorf("wrong typed nils:\n got %s\nwant %s", out, wanted)
+	}
+	for _, name := range Registered() {
+		format, lookupErr := Lookup(name, nil)
+		if lookupErr != nil {
+			t.Fatal(lookupErr)
+		}
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("%s panicked on typed nils: %v", name, r)
+				}
+			}()
+			format.FormatAndNormalize(logf.Error, "test log", props())
+		}()
+	}
+}
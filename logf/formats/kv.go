@@ -15,15 +15,33 @@
 package formats
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/decentplatforms/appkit/logf"
 )
 
+var KVSyntaxError = errors.New("invalid logfmt")
+
+// KVConfig sets default values for KVFormat.
+// Usage notes:
+//   - Logfmt writes spec-compliant logfmt, which ParseKV reads. It writes the level keyword
+//     instead of the level number, and quotes and escapes values only when needed.
+//   - UseSingleQuotes doesn't apply to Logfmt, which always uses double quotes. Without Logfmt,
+//     backslashes and the quote character are escaped with a backslash in the message and quoted
+//     values.
+//   - LevelNames names levels in Logfmt mode, instead of the logger's Config.LevelNames
+//   - Props in a logf.Group are written with dotted keys, like http.method
 type KVConfig struct {
 	TimeFormat      string
 	UseSingleQuotes bool
+	Logfmt          bool
+	LevelNames      *logf.LevelNames
 }
 
 func (conf KVConfig) withDefaults() KVConfig {
@@ -34,25 +52,27 @@ func (conf KVConfig) withDefaults() KVConfig {
 	return conf
 }
 
-func formatProps(props *logf.Props, useSingleQuotes bool) string {
-	if props == nil {
-		return ""
-	}
+// singleQuoteEscaper and doubleQuoteEscaper escape the message and quoted values in KVFormat's
+// default mode, so values with quotes, like lists and objects written as JSON, can't end the value
+// early.
+var singleQuoteEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+var doubleQuoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
 
-	propsIter := props.Slice()
+func formatProps(props logf.PropsView, useSingleQuotes bool) string {
+	propsIter := flattenGroups(props.Slice())
 
 	raw := ""
 
 	for _, prop := range propsIter {
 		// reflect check for int,floats,uint,bool
 		switch prop.Value.(type) {
-		case int, float64, uint, bool:
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, bool:
 			raw += fmt.Sprintf("%s=%v ", prop.Name, prop.Value)
 		default:
 			if useSingleQuotes {
-				raw += fmt.Sprintf("%s='%v' ", prop.Name, prop.Value)
+				raw += fmt.Sprintf("%s='%s' ", prop.Name, singleQuoteEscaper.Replace(textValue(prop.Value)))
 			} else {
-				raw += fmt.Sprintf("%s=\"%v\" ", prop.Name, prop.Value)
+				raw += fmt.Sprintf("%s=\"%s\" ", prop.Name, doubleQuoteEscaper.Replace(textValue(prop.Value)))
 			}
 		}
 	}
@@ -65,16 +85,165 @@ func formatProps(props *logf.Props, useSingleQuotes bool) string {
 }
 
 func KVFormat(conf KVConfig) logf.Formatter {
-	return func(level logf.LogLevel, msg string, props *logf.Props) string {
+	conf = conf.withDefaults()
+	if conf.Logfmt {
+		return logfmtFormat(conf)
+	}
+	return func(level logf.LogLevel, msg string, props logf.PropsView) string {
 
 		timestamp := time.Now().UTC().Format(conf.TimeFormat)
 
 		formattedProps := formatProps(props, conf.UseSingleQuotes)
 
 		if conf.UseSingleQuotes {
-			return fmt.Sprintf("level=%d timestamp=%s message='%s' %s", level, timestamp, msg, formattedProps)
+			return fmt.Sprintf("level=%d timestamp=%s message='%s' %s", level, timestamp, singleQuoteEscaper.Replace(msg), formattedProps)
 		} else {
-			return fmt.Sprintf("level=%d timestamp=%s message=\"%s\" %s", level, timestamp, msg, formattedProps)
+			return fmt.Sprintf("level=%d timestamp=%s message=\"%s\" %s", level, timestamp, doubleQuoteEscaper.Replace(msg), formattedProps)
+		}
+	}
+}
+
+func logfmtFormat(conf KVConfig) logf.Formatter {
+	return func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		var out strings.Builder
+		out.WriteString("level=")
+		out.WriteString(LogfmtValue(levelName(conf.LevelNames, level, props)))
+		out.WriteString(" timestamp=")
+		out.WriteString(LogfmtValue(time.Now().UTC().Format(conf.TimeFormat)))
+		out.WriteString(" message=")
+		out.WriteString(LogfmtValue(msg))
+		for _, prop := range flattenGroups(props.Slice()) {
+			out.WriteByte(' ')
+			out.WriteString(LogfmtKey(prop.Name))
+			out.WriteByte('=')
+			out.WriteString(LogfmtValue(prop.Value))
+		}
+		return out.String()
+	}
+}
+
+// flattenGroups replaces logf.Group props with their members, named by dotted path like
+// http.method, so text formats can write groups as plain keys.
+func flattenGroups(props []logf.Prop) []logf.Prop {
+	return appendFlattened(make([]logf.Prop, 0, len(props)), "", props)
+}
+
+func appendFlattened(flat []logf.Prop, prefix string, props []logf.Prop) []logf.Prop {
+	for _, prop := range props {
+		if group, ok := prop.Value.(logf.PropGroup); ok {
+			flat = appendFlattened(flat, prefix+prop.Name+".", group)
+			continue
+		}
+		prop.Name = prefix + prop.Name
+		flat = append(flat, prop)
+	}
+	return flat
+}
+
+// LogfmtKey makes name safe to use as a logfmt key.
+// Spaces, '=', '"', and control or non-printing characters are replaced with _, and an empty
+// name becomes _.
+func LogfmtKey(name string) string {
+	if name == "" {
+		return "_"
+	}
+	if strings.IndexFunc(name, logfmtUnsafe) < 0 {
+		return name
+	}
+	return strings.Map(func(r rune) rune {
+		if logfmtUnsafe(r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// LogfmtValue formats a value for logfmt.
+// Errors are written as their message, []byte as base64, times as RFC 3339 in UTC, stacks with
+// StackTrace, and lists, objects, and structs as compact JSON; everything else is written with
+// fmt.Sprint. The result is quoted only if it's empty, or has spaces, '=', '"', control or
+// non-printing characters, or invalid UTF-8. Quoted values use Go escapes, so strconv.Unquote
+// reverses them.
+func LogfmtValue(value any) string {
+	str, ok := value.(string)
+	if !ok {
+		str = textValue(value)
+	}
+	if str == "" || !utf8.ValidString(str) || strings.IndexFunc(str, logfmtUnsafe) >= 0 {
+		return strconv.Quote(str)
+	}
+	return str
+}
+
+func logfmtUnsafe(r rune) bool {
+	return r == '=' || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r)
+}
+
+// ParseKV parses a line of logfmt, like KVFormat writes with Logfmt set.
+// Props are returned in order, with string values. A key without a value has the value "".
+func ParseKV(line string) ([]logf.Prop, error) {
+	props := []logf.Prop{}
+	i := 0
+	for {
+		for i < len(line) && isKVSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return props, nil
+		}
+		start := i
+		for i < len(line) && line[i] != '=' && !isKVSpace(line[i]) && line[i] != '"' {
+			i++
+		}
+		key := line[start:i]
+		if key == "" {
+			return nil, fmt.Errorf("%w: expected key at offset %d", KVSyntaxError, start)
+		}
+		if i == len(line) || isKVSpace(line[i]) {
+			props = append(props, logf.String(key, ""))
+			continue
+		}
+		if line[i] == '"' {
+			return nil, fmt.Errorf("%w: unexpected quote at offset %d", KVSyntaxError, i)
+		}
+		i++
+		if i < len(line) && line[i] == '"' {
+			end, err := closingQuote(line, i)
+			if err != nil {
+				return nil, err
+			}
+			value, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: bad escape in value at offset %d", KVSyntaxError, i)
+			}
+			props = append(props, logf.String(key, value))
+			i = end + 1
+			continue
+		}
+		start = i
+		for i < len(line) && !isKVSpace(line[i]) {
+			if line[i] == '"' || line[i] == '=' {
+				return nil, fmt.Errorf("%w: unexpected %q at offset %d", KVSyntaxError, line[i], i)
+			}
+			i++
+		}
+		props = append(props, logf.String(key, line[start:i]))
+	}
+}
+
+func isKVSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// closingQuote returns the index of the quote that closes the quoted string starting at line[start].
+func closingQuote(line string, start int) (int, error) {
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			return i, nil
 		}
 	}
+	return 0, fmt.Errorf("%w: unterminated quote at offset %d", KVSyntaxError, start)
 }
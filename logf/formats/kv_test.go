@@ -0,0 +1,143 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+func TestKVTypes(t *testing.T) {
+	format := KVFormat(KVConfig{})
+	out := format.FormatAndNormalize(logf.Informational, "test log", logf.NewProps(
+		logf.Int("i64", int64(1)),
+		logf.UInt("u64", uint64(2)),
+		logf.Prop{Name: "f32", Value: float32(1.5)},
+	))
+	if !strings.HasSuffix(out, ` i64=1 u64=2 f32=1.5`+"\n") {
+		t.Errorf("numbers shouldn't be quoted: %q", out)
+	}
+
+	props := func() *logf.Props {
+		return logf.NewProps(
+			logf.Strings("s", []string{"a", "b c"}),
+			logf.Prop{Name: "o", Value: []logf.Prop{logf.Int("x", 1)}},
+			logf.String("q", `it's \ "done"`),
+		)
+	}
+	double := format.FormatAndNormalize(logf.Informational, "test log", props())
+	single := KVFormat(KVConfig{UseSingleQuotes: true}).FormatAndNormalize(logf.Informational, "test log", props())
+	if !strings.HasSuffix(double, ` s="[\"a\",\"b c\"]" o="{\"x\":1}" q="it's \\ \"done\""`+"\n") {
+		t.Errorf("wrong double-quoted escapes: %q", double)
+	}
+	if !strings.HasSuffix(single, ` s='["a","b c"]' o='{"x":1}' q='it\'s \\ "done"'`+"\n") {
+		t.Errorf("wrong single-quoted escapes: %q", single)
+	}
+	parsed, err := ParseKV(double[strings.Index(double, " s="):])
+	if err != nil || len(parsed) != 3 || parsed[0].Value != `["a","b c"]` || parsed[2].Value != `it's \ "done"` {
+		t.Errorf("double-quoted props don't split back into pairs: %v %v", parsed, err)
+	}
+
+	msg := `it's "quoted" \ here`
+	double = format.FormatAndNormalize(logf.Informational, msg, logf.NewProps(logf.String("a", "1")))
+	single = KVFormat(KVConfig{UseSingleQuotes: true}).FormatAndNormalize(logf.Informational, msg, logf.NewProps(logf.String("a", "1")))
+	if !strings.HasSuffix(double, ` message="it's \"quoted\" \\ here" a="1"`+"\n") {
+		t.Errorf("wrong double-quoted message: %q", double)
+	}
+	if !strings.HasSuffix(single, ` message='it\'s "quoted" \\ here' a='1'`+"\n") {
+		t.Errorf("wrong single-quoted message: %q", single)
+	}
+	parsed, err = ParseKV(double[strings.Index(double, " message="):])
+	if err != nil || len(parsed) != 2 || parsed[0].Value != msg {
+		t.Errorf("double-quoted message doesn't split back into pairs: %v %v", parsed, err)
+	}
+}
+
+func TestLogfmt(t *testing.T) {
+	format := KVFormat(KVConfig{Logfmt: true})
+	props := []logf.Prop{
+		logf.String("plain", "value"),
+		logf.String("spaces", "two words"),
+		logf.String("quotes", `say "hi"`),
+		logf.String("escapes", "back\\slash\nnewline\ttab"),
+		logf.String("equals", "a=b"),
+		logf.String("empty", ""),
+		logf.String("unicode", "héllo"),
+		logf.String("bad key=", "x"),
+		logf.Int("num", 42),
+	}
+	out := format.FormatAndNormalize(logf.Warning, "test \"log\"", logf.NewProps(props...))
+	if strings.Count(out, "\n") != 1 {
+		t.Fatalf("logfmt line has raw newlines: %q", out)
+	}
+	if !strings.HasPrefix(out, "level=warn timestamp=") || !strings.Contains(out, ` plain=value spaces="two words" `) {
+		t.Errorf("wrong quoting: %q", out)
+	}
+
+	parsed, err := ParseKV(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != len(props)+3 {
+		t.Fatalf("wanted %d props, got %d: %v", len(props)+3, len(parsed), parsed)
+	}
+	if parsed[0] != logf.String("level", "warn") || parsed[2] != logf.String("message", `test "log"`) {
+		t.Errorf("wrong header fields: %v", parsed[:3])
+	}
+	if _, err := time.Parse(time.RFC3339, parsed[1].Value.(string)); parsed[1].Name != "timestamp" || err != nil {
+		t.Errorf("wanted an RFC 3339 timestamp by default, got %v", parsed[1])
+	}
+	for i, prop := range props {
+		wanted := logf.String(LogfmtKey(prop.Name), fmt.Sprint(prop.Value))
+		if parsed[i+3] != wanted {
+			t.Errorf("wanted %v, got %v", wanted, parsed[i+3])
+		}
+	}
+}
+
+func TestLogfmtStack(t *testing.T) {
+	frames := []logf.Frame{{Function: "main.run", File: "main.go", Line: 12}, {Function: "main.main", File: "main.go", Line: 5}}
+	out := KVFormat(KVConfig{Logfmt: true}).FormatAndNormalize(logf.Critical, "test log", logf.NewProps(logf.Prop{Name: logf.STACK, Value: frames}))
+	if strings.Count(out, "\n") != 1 {
+		t.Fatalf("logfmt line has raw newlines: %q", out)
+	}
+	parsed, err := ParseKV(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stack := parsed[len(parsed)-1]; stack != logf.String(logf.STACK, "main.run\n\tmain.go:12\nmain.main\n\tmain.go:5\n") {
+		t.Errorf("wrong stack value: %v", stack)
+	}
+}
+
+func TestParseKV(t *testing.T) {
+	parsed, err := ParseKV(`a=1 flag b="x y"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wanted := []logf.Prop{logf.String("a", "1"), logf.String("flag", ""), logf.String("b", "x y")}
+	if fmt.Sprint(parsed) != fmt.Sprint(wanted) {
+		t.Errorf("wanted %v, got %v", wanted, parsed)
+	}
+	for _, line := range []string{`a="unterminated`, `=value`, `a=b"c`, `"key"=1`, `a="\q"`} {
+		if _, err := ParseKV(line); err == nil {
+			t.Errorf("expected error parsing %q", line)
+		}
+	}
+}
@@ -49,7 +49,25 @@ var formats = map[string]logf.Formatter{
 		TimeFormat:      time.RFC3339,
 		UseSingleQuotes: true,
 	}),
+	"logfmt": KVFormat(KVConfig{
+		TimeFormat: time.RFC3339,
+		Logfmt:     true,
+	}),
 	"json_pretty": JSONPrettyFormat(JSONConfig{Indent: "  ", TimeFormat: time.RFC3339}),
+	"journald":    JournaldFormat(JournaldConfig{Identifier: "test"}),
+	"template":    mustTemplate(TemplateConfig{Template: "{{.Level}} {{.Message}} {{logfmt .Props}}"}),
+	"layout":      mustTemplate(TemplateConfig{Template: "{level} {message} {props}"}),
+	"cbor":        CBORFormat(JSONConfig{}),
+	"msgpack":     MsgPackFormat(JSONConfig{}),
+	"gcp":         GCPFormat(GCPConfig{JSONConfig: JSONConfig{TimeFormat: time.RFC3339}}),
+}
+
+func mustTemplate(conf TemplateConfig) logf.Formatter {
+	format, err := TemplateFormat(conf)
+	if err != nil {
+		panic(err)
+	}
+	return format
 }
 
 func testProps() []logf.Prop {
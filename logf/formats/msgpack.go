@@ -0,0 +1,229 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+// MsgPackFormat provides the JSONFormat record encoded as MessagePack, using the same conf.
+// Each record is one map, prefixed with its length as a 4 byte big-endian uint, and isn't
+// followed by a newline. Read records back with NewMsgPackDecoder.
+//
+// Values are encoded as in JSONFormat, except that []byte is bin, floats are always float 64
+// (including NaN and infinities), and structs are maps.
+func MsgPackFormat(conf JSONConfig) logf.Formatter {
+	return binaryFormat(conf, appendMsgPack)
+}
+
+// appendMsgPackHead appends the header for a str, bin, array, or map of length n.
+// The fixed form holds lengths up to fixedMax, which is -1 if there isn't one, and t8 is 0 if
+// there isn't an 8 bit form.
+func appendMsgPackHead(buf []byte, n int, fixed byte, fixedMax int, t8, t16, t32 byte) []byte {
+	switch {
+	case n <= fixedMax:
+		return append(buf, fixed|byte(n))
+	case t8 != 0 && n <= math.MaxUint8:
+		return append(buf, t8, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, t16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(buf, t32), uint32(n))
+}
+
+func appendMsgPack(buf []byte, value any) []byte {
+	switch v := value.(type) {
+	case nil:
+		return append(buf, 0xc0)
+	case bool:
+		if v {
+			return append(buf, 0xc3)
+		}
+		return append(buf, 0xc2)
+	case int64:
+		switch {
+		case v >= 0:
+			return appendMsgPack(buf, uint64(v))
+		case v >= -32:
+			return append(buf, byte(v))
+		case v >= math.MinInt8:
+			return append(buf, 0xd0, byte(v))
+		case v >= math.MinInt16:
+			return binary.BigEndian.AppendUint16(append(buf, 0xd1), uint16(v))
+		case v >= math.MinInt32:
+			return binary.BigEndian.AppendUint32(append(buf, 0xd2), uint32(v))
+		}
+		return binary.BigEndian.AppendUint64(append(buf, 0xd3), uint64(v))
+	case uint64:
+		switch {
+		case v <= 0x7f:
+			return append(buf, byte(v))
+		case v <= math.MaxUint8:
+			return append(buf, 0xcc, byte(v))
+		case v <= math.MaxUint16:
+			return binary.BigEndian.AppendUint16(append(buf, 0xcd), uint16(v))
+		case v <= math.MaxUint32:
+			return binary.BigEndian.AppendUint32(append(buf, 0xce), uint32(v))
+		}
+		return binary.BigEndian.AppendUint64(append(buf, 0xcf), v)
+	case float64:
+		return binary.BigEndian.AppendUint64(append(buf, 0xcb), math.Float64bits(v))
+	case string:
+		return append(appendMsgPackHead(buf, len(v), 0xa0, 31, 0xd9, 0xda, 0xdb), v...)
+	case []byte:
+		return append(appendMsgPackHead(buf, len(v), 0, -1, 0xc4, 0xc5, 0xc6), v...)
+	case []any:
+		buf = appendMsgPackHead(buf, len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			buf = appendMsgPack(buf, item)
+		}
+		return buf
+	case object:
+		buf = appendMsgPackHead(buf, len(v), 0x80, 15, 0, 0xde, 0xdf)
+		for _, f := range v {
+			buf = appendMsgPack(buf, f.name)
+			buf = appendMsgPack(buf, f.value)
+		}
+		return buf
+	}
+	return appendMsgPack(buf, fmt.Sprint(value))
+}
+
+// decodeMsgPack decodes one MessagePack value. Ext types aren't supported.
+func decodeMsgPack(br *binaryReader) (any, error) {
+	b, err := br.byte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xe0 == 0xa0:
+		return decodeMsgPackStr(br, uint64(b&0x1f))
+	case b&0xf0 == 0x90:
+		return decodeMsgPackArray(br, uint64(b&0x0f))
+	case b&0xf0 == 0x80:
+		return decodeMsgPackMap(br, uint64(b&0x0f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xca:
+		bits, err := br.uint(4)
+		return float64(math.Float32frombits(uint32(bits))), err
+	case 0xcb:
+		bits, err := br.uint(8)
+		return math.Float64frombits(bits), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := br.uint(1 << (b - 0xcc))
+		if err != nil || v > math.MaxInt64 {
+			return v, err
+		}
+		return int64(v), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		v, err := br.uint(size)
+		// Sign-extend from size bytes.
+		shift := 64 - 8*size
+		return int64(v<<shift) >> shift, err
+	}
+
+	var kind byte
+	var size int
+	switch b {
+	case 0xd9, 0xda, 0xdb:
+		kind, size = 's', 1<<(b-0xd9)
+	case 0xc4, 0xc5, 0xc6:
+		kind, size = 'b', 1<<(b-0xc4)
+	case 0xdc, 0xdd:
+		kind, size = 'a', 2<<(b-0xdc)
+	case 0xde, 0xdf:
+		kind, size = 'm', 2<<(b-0xde)
+	default:
+		return nil, fmt.Errorf("%w: unsupported MessagePack type 0x%02x", BinarySyntaxError, b)
+	}
+	n, err := br.uint(size)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case 's':
+		return decodeMsgPackStr(br, n)
+	case 'b':
+		raw, err := br.next(n)
+		return append([]byte{}, raw...), err
+	case 'a':
+		return decodeMsgPackArray(br, n)
+	}
+	return decodeMsgPackMap(br, n)
+}
+
+func decodeMsgPackStr(br *binaryReader, n uint64) (any, error) {
+	raw, err := br.next(n)
+	return string(raw), err
+}
+
+func decodeMsgPackArray(br *binaryReader, n uint64) (any, error) {
+	leave, err := br.enter()
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+	if err := br.checkCount(n); err != nil {
+		return nil, err
+	}
+	list := make([]any, n)
+	for i := range list {
+		if list[i], err = decodeMsgPack(br); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+func decodeMsgPackMap(br *binaryReader, n uint64) (any, error) {
+	leave, err := br.enter()
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+	if err := br.checkCount(n); err != nil {
+		return nil, err
+	}
+	props := make([]logf.Prop, n)
+	for i := range props {
+		key, err := decodeMsgPack(br)
+		if err != nil {
+			return nil, err
+		}
+		value, err := decodeMsgPack(br)
+		if err != nil {
+			return nil, err
+		}
+		props[i] = logf.Prop{Name: decodedKey(key), Value: value}
+	}
+	return props, nil
+}
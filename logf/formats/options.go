@@ -0,0 +1,349 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var UnknownOptionError = errors.New("unknown option")
+var OptionTypeError = errors.New("wrong option type")
+var OptionValueError = errors.New("bad option value")
+
+// OptionError describes a bad format option.
+// Option is the path to the option within the options map, like indent or metrics[1].unit.
+type OptionError struct {
+	Format string
+	Option string
+	Err    error
+}
+
+func (err *OptionError) Error() string {
+	if err.Format == "" {
+		return fmt.Sprintf("option %s: %v", err.Option, err.Err)
+	}
+	return fmt.Sprintf("format %s: option %s: %v", err.Format, err.Option, err.Err)
+}
+
+func (err *OptionError) Unwrap() error {
+	return err.Err
+}
+
+// Options configure a format, usually as decoded from a JSON or YAML config file.
+type Options map[string]any
+
+var durationType = reflect.TypeOf(time.Duration(0))
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// DecodeOptions sets the fields of the config struct that conf points to from opts.
+// Usage notes:
+//   - Option names are field names in snake_case, like time_format for TimeFormat. Fields of
+//     embedded structs are options of the outer struct.
+//   - Numbers may be any numeric type, as long as the value fits in the field
+//   - time.Duration and encoding.TextUnmarshaler fields take strings, like "5s". So do pointers
+//     to TextUnmarshalers, like level_names for *logf.LevelNames.
+//   - Struct fields take maps, slices take lists, and maps take maps with string keys
+//   - Fields that can't come from a config file, like funcs and interfaces, aren't options
+//
+// Errors are *OptionError, wrapping UnknownOptionError, OptionTypeError, or OptionValueError.
+func DecodeOptions(opts map[string]any, conf any) error {
+	rv := reflect.ValueOf(conf)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		panic("formats: DecodeOptions needs a pointer to a struct")
+	}
+	return decodeStruct("", opts, rv.Elem())
+}
+
+// OptionName returns the option name for a config struct field.
+func OptionName(field string) string {
+	runes := []rune(field)
+	var out strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				out.WriteByte('_')
+			}
+		}
+		out.WriteRune(unicode.ToLower(r))
+	}
+	return out.String()
+}
+
+func optionPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// optionFields returns the settable fields of a struct by option name, including the fields of
+// embedded structs.
+func optionFields(rv reflect.Value, fields map[string]reflect.Value) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			optionFields(rv.Field(i), fields)
+			continue
+		}
+		if decodable(sf.Type) {
+			fields[OptionName(sf.Name)] = rv.Field(i)
+		}
+	}
+}
+
+func decodable(rt reflect.Type) bool {
+	if rt == durationType || reflect.PointerTo(rt).Implements(textUnmarshalerType) {
+		return true
+	}
+	if rt.Kind() == reflect.Pointer && rt.Implements(textUnmarshalerType) {
+		return true
+	}
+	switch rt.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Struct:
+		return true
+	case reflect.Slice:
+		return decodable(rt.Elem())
+	case reflect.Map:
+		return rt.Key().Kind() == reflect.String && decodable(rt.Elem())
+	}
+	return false
+}
+
+func decodeStruct(path string, opts map[string]any, rv reflect.Value) error {
+	fields := map[string]reflect.Value{}
+	optionFields(rv, fields)
+	names := make([]string, 0, len(opts))
+	for name := range opts {
+		names = append(names, name)
+	}
+	// Sorted, so the same bad options always report the same error.
+	sort.Strings(names)
+	for _, name := range names {
+		field, ok := fields[name]
+		if !ok {
+			return &OptionError{Option: optionPath(path, name), Err: UnknownOptionError}
+		}
+		if err := decodeValue(optionPath(path, name), opts[name], field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeValue(path string, value any, rv reflect.Value) error {
+	typeErr := func() error {
+		return &OptionError{Option: path, Err: fmt.Errorf("%w: can't use %T for %s", OptionTypeError, value, rv.Type())}
+	}
+	valueErr := func(err error) error {
+		return &OptionError{Option: path, Err: fmt.Errorf("%w: %w", OptionValueError, err)}
+	}
+
+	// null options, like an empty YAML key, leave the default.
+	if value == nil {
+		return nil
+	}
+	// JSON decoders using UseNumber give json.Number.
+	if n, ok := value.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			value = i
+		} else if f, err := n.Float64(); err == nil {
+			value = f
+		}
+	}
+
+	if rv.Type() == durationType {
+		str, ok := value.(string)
+		if !ok {
+			return typeErr()
+		}
+		d, err := time.ParseDuration(str)
+		if err != nil {
+			return valueErr(err)
+		}
+		rv.SetInt(int64(d))
+		return nil
+	}
+	if rv.Addr().Type().Implements(textUnmarshalerType) {
+		str, ok := value.(string)
+		if !ok {
+			return typeErr()
+		}
+		if err := rv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(str)); err != nil {
+			return valueErr(err)
+		}
+		return nil
+	}
+	if rv.Kind() == reflect.Pointer && rv.Type().Implements(textUnmarshalerType) {
+		str, ok := value.(string)
+		if !ok {
+			return typeErr()
+		}
+		ptr := reflect.New(rv.Type().Elem())
+		if err := ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(str)); err != nil {
+			return valueErr(err)
+		}
+		rv.Set(ptr)
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return typeErr()
+		}
+		rv.SetBool(b)
+	case reflect.String:
+		str, ok := value.(string)
+		if !ok {
+			return typeErr()
+		}
+		rv.SetString(str)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// A one-character string sets a rune, like a delimiter.
+		if str, ok := value.(string); ok && rv.Kind() == reflect.Int32 && utf8.RuneCountInString(str) == 1 {
+			r, _ := utf8.DecodeRuneInString(str)
+			rv.SetInt(int64(r))
+			return nil
+		}
+		i, ok, fits := optionInt(value)
+		if !ok {
+			return typeErr()
+		}
+		if !fits || rv.OverflowInt(i) {
+			return valueErr(fmt.Errorf("%v doesn't fit in %s", value, rv.Type()))
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		if n := reflect.ValueOf(value); n.CanUint() {
+			u = n.Uint()
+		} else {
+			i, ok, fits := optionInt(value)
+			if !ok {
+				return typeErr()
+			}
+			if !fits || i < 0 {
+				return valueErr(fmt.Errorf("%v doesn't fit in %s", value, rv.Type()))
+			}
+			u = uint64(i)
+		}
+		if rv.OverflowUint(u) {
+			return valueErr(fmt.Errorf("%v doesn't fit in %s", value, rv.Type()))
+		}
+		rv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, ok := optionNumber(value)
+		if !ok {
+			return typeErr()
+		}
+		rv.SetFloat(f)
+	case reflect.Struct:
+		opts, ok := optionMap(value)
+		if !ok {
+			return typeErr()
+		}
+		return decodeStruct(path, opts, rv)
+	case reflect.Slice:
+		list, ok := value.([]any)
+		if !ok {
+			return typeErr()
+		}
+		slice := reflect.MakeSlice(rv.Type(), len(list), len(list))
+		for i, item := range list {
+			if err := decodeValue(fmt.Sprintf("%s[%d]", path, i), item, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+	case reflect.Map:
+		opts, ok := optionMap(value)
+		if !ok {
+			return typeErr()
+		}
+		m := reflect.MakeMapWithSize(rv.Type(), len(opts))
+		for key, item := range opts {
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			if err := decodeValue(optionPath(path, key), item, elem); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()), elem)
+		}
+		rv.Set(m)
+	default:
+		return typeErr()
+	}
+	return nil
+}
+
+// optionInt converts a numeric option to an int64. fits is false if it's a float with a
+// fractional part, or it's out of range.
+func optionInt(value any) (i int64, ok, fits bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), true, rv.Uint() <= math.MaxInt64
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		return int64(f), true, f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64
+	}
+	return 0, false, false
+}
+
+// optionNumber converts a numeric option to a float64.
+func optionNumber(value any) (float64, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// optionMap accepts maps with string keys, including Options.
+func optionMap(value any) (map[string]any, bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		return v, true
+	case Options:
+		return v, true
+	}
+	return nil, false
+}
@@ -0,0 +1,258 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+const (
+	OTEL_TRACE_ID = string("trace_id")
+	OTEL_SPAN_ID  = string("span_id")
+)
+
+// OTel log data model types, following the OTLP JSON encoding.
+type otelResourceLogs struct {
+	Resource  otelResource    `json:"resource"`
+	ScopeLogs []otelScopeLogs `json:"scopeLogs"`
+}
+
+type otelResource struct {
+	Attributes []otelKeyValue `json:"attributes,omitempty"`
+}
+
+type otelScopeLogs struct {
+	Scope      otelScope       `json:"scope"`
+	LogRecords []otelLogRecord `json:"logRecords"`
+}
+
+type otelScope struct {
+	Name string `json:"name"`
+}
+
+type otelLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 otelAnyValue   `json:"body"`
+	Attributes           []otelKeyValue `json:"attributes,omitempty"`
+	TraceID              string         `json:"traceId,omitempty"`
+	SpanID               string         `json:"spanId,omitempty"`
+}
+
+type otelKeyValue struct {
+	Key   string       `json:"key"`
+	Value otelAnyValue `json:"value"`
+}
+
+// otelAnyValue holds exactly one value. Integers are strings, as in the protobuf JSON mapping.
+type otelAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BytesValue  []byte   `json:"bytesValue,omitempty"`
+
+	ArrayValue  *otelArrayValue  `json:"arrayValue,omitempty"`
+	KvlistValue *otelKvlistValue `json:"kvlistValue,omitempty"`
+}
+
+type otelArrayValue struct {
+	Values []otelAnyValue `json:"values"`
+}
+
+type otelKvlistValue struct {
+	Values []otelKeyValue `json:"values"`
+}
+
+// OTelConfig sets default values for OTelFormat.
+// Usage notes:
+//   - ServiceName and ServiceVersion are the service.name and service.version resource attributes
+//   - Resource adds other resource attributes
+//   - ScopeName is the instrumentation scope, and defaults to the logf module path
+type OTelConfig struct {
+	ServiceName    string
+	ServiceVersion string
+	Resource       map[string]string
+	ScopeName      string
+}
+
+func (conf OTelConfig) withDefaults() OTelConfig {
+	if conf.ScopeName == "" {
+		conf.ScopeName = "github.com/decentplatforms/appkit/logf"
+	}
+	return conf
+}
+
+// OTelSeverity maps a LogLevel onto the OpenTelemetry SeverityNumber and its short name.
+// Levels outside of the syslog range are clamped to the nearest syslog level.
+func OTelSeverity(level logf.LogLevel) (int, string) {
+	switch {
+	case level <= logf.Emergency:
+		return 24, "FATAL4"
+	case level == logf.Alert:
+		return 23, "FATAL3"
+	case level == logf.Critical:
+		return 21, "FATAL"
+	case level == logf.Error:
+		return 17, "ERROR"
+	case level == logf.Warning:
+		return 13, "WARN"
+	case level == logf.Notice:
+		return 10, "INFO2"
+	case level == logf.Informational:
+		return 9, "INFO"
+	default:
+		return 5, "DEBUG"
+	}
+}
+
+// OTelFormat provides the OpenTelemetry log data model, encoded as one OTLP JSON ResourceLogs
+// object per record, for use with output.OTLP:
+//   - severityNumber and severityText come from OTelSeverity
+//   - body is the log message
+//   - Props become attributes, in order. Integers, floats, bools, and []byte keep their types,
+//     lists are arrayValues, and objects, like logf.Object props, are kvlistValues. Everything else
+//     is a string.
+//   - The OTEL_TRACE_ID and OTEL_SPAN_ID props are written as traceId and spanId instead.
+//   - The logf.SOURCE frame from Config.AddCaller is written as the code.filepath, code.lineno, and
+//     code.function attributes
+//   - An ERROR prop from logf.Err is written as the exception.message, exception.type, and
+//     exception.stacktrace attributes
+//   - The resource holds the service info from conf
+func OTelFormat(conf OTelConfig) logf.Formatter {
+	conf = conf.withDefaults()
+	resource := otelResource{}
+	if conf.ServiceName != "" {
+		resource.Attributes = append(resource.Attributes, otelKeyValue{Key: "service.name", Value: otelValue(conf.ServiceName)})
+	}
+	if conf.ServiceVersion != "" {
+		resource.Attributes = append(resource.Attributes, otelKeyValue{Key: "service.version", Value: otelValue(conf.ServiceVersion)})
+	}
+	keys := make([]string, 0, len(conf.Resource))
+	for key := range conf.Resource {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		resource.Attributes = append(resource.Attributes, otelKeyValue{Key: key, Value: otelValue(conf.Resource[key])})
+	}
+	scope := otelScope{Name: conf.ScopeName}
+
+	return func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		now := strconv.FormatInt(time.Now().UnixNano(), 10)
+		record := otelLogRecord{
+			TimeUnixNano:         now,
+			ObservedTimeUnixNano: now,
+			Body:                 otelValue(msg),
+		}
+		record.SeverityNumber, record.SeverityText = OTelSeverity(level)
+		record.TraceID, _ = props.Get(OTEL_TRACE_ID).(string)
+		record.SpanID, _ = props.Get(OTEL_SPAN_ID).(string)
+		if source, ok := props.Get(logf.SOURCE).(logf.Frame); ok {
+			record.Attributes = append(record.Attributes,
+				otelKeyValue{Key: "code.filepath", Value: otelValue(source.File)},
+				otelKeyValue{Key: "code.lineno", Value: otelValue(source.Line)},
+				otelKeyValue{Key: "code.function", Value: otelValue(source.Function)},
+			)
+		}
+		for _, prop := range props.Without(OTEL_TRACE_ID, OTEL_SPAN_ID, logf.SOURCE).Slice() {
+			if errValue, ok := prop.Value.(*logf.ErrorValue); ok && errValue != nil && prop.Name == logf.ERROR {
+				record.Attributes = append(record.Attributes,
+					otelKeyValue{Key: "exception.message", Value: otelValue(errValue.Message)},
+					otelKeyValue{Key: "exception.type", Value: otelValue(errValue.Type)},
+				)
+				if stack := StackTrace(errorStack(errValue)); stack != "" {
+					record.Attributes = append(record.Attributes, otelKeyValue{Key: "exception.stacktrace", Value: otelValue(stack)})
+				}
+				continue
+			}
+			record.Attributes = append(record.Attributes, otelKeyValue{Key: prop.Name, Value: otelValue(prop.Value)})
+		}
+
+		raw, _ := json.Marshal(otelResourceLogs{
+			Resource:  resource,
+			ScopeLogs: []otelScopeLogs{{Scope: scope, LogRecords: []otelLogRecord{record}}},
+		})
+		return string(raw)
+	}
+}
+
+func otelValue(value any) otelAnyValue {
+	switch value.(type) {
+	case nil, error, logf.Frame, []logf.Frame:
+		str := textValue(value)
+		return otelAnyValue{StringValue: &str}
+	}
+	return otelNormalized(normalize(value))
+}
+
+// otelNormalized converts a normalized value. Lists are arrayValues and objects are kvlistValues.
+func otelNormalized(value any) otelAnyValue {
+	var str string
+	switch v := value.(type) {
+	case string:
+		str = v
+	case bool:
+		return otelAnyValue{BoolValue: &v}
+	case int64:
+		str = strconv.FormatInt(v, 10)
+		return otelAnyValue{IntValue: &str}
+	case uint64:
+		// intValue is signed, so uints that don't fit are sent as strings.
+		str = strconv.FormatUint(v, 10)
+		if v <= math.MaxInt64 {
+			return otelAnyValue{IntValue: &str}
+		}
+	case float64:
+		return otelDouble(v)
+	case []byte:
+		return otelAnyValue{BytesValue: v}
+	case []any:
+		array := &otelArrayValue{Values: make([]otelAnyValue, len(v))}
+		for i, item := range v {
+			array.Values[i] = otelNormalized(item)
+		}
+		return otelAnyValue{ArrayValue: array}
+	case object:
+		kvlist := &otelKvlistValue{Values: make([]otelKeyValue, len(v))}
+		for i, f := range v {
+			kvlist.Values[i] = otelKeyValue{Key: f.name, Value: otelNormalized(f.value)}
+		}
+		return otelAnyValue{KvlistValue: kvlist}
+	case rawJSON:
+		str = string(v)
+	default:
+		str = fmt.Sprint(v)
+	}
+	return otelAnyValue{StringValue: &str}
+}
+
+// otelDouble returns a doubleValue, or a stringValue for NaN and infinities, which JSON can't represent.
+func otelDouble(f float64) otelAnyValue {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		str := strconv.FormatFloat(f, 'g', -1, 64)
+		return otelAnyValue{StringValue: &str}
+	}
+	return otelAnyValue{DoubleValue: &f}
+}
@@ -0,0 +1,155 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+var UnknownFormatError = errors.New("unknown log format")
+var DuplicateFormatError = errors.New("log format is already registered")
+var InvalidFormatError = errors.New("log format needs a name and constructor")
+
+// Constructor builds a formatter from options.
+// Errors about specific options should be *OptionError; DecodeOptions returns them for you.
+type Constructor func(opts Options) (logf.Formatter, error)
+
+var registry = struct {
+	sync.RWMutex
+	formats map[string]Constructor
+}{
+	formats: map[string]Constructor{
+		"syslog3164":  syslogConstructor(Syslog3164Format),
+		"syslog5424":  syslogConstructor(Syslog5424Format),
+		"json":        ConfigConstructor(JSONConfig{}, noError(JSONFormat)),
+		"json_pretty": ConfigConstructor(JSONConfig{}, noError(JSONPrettyFormat)),
+		"kv":          ConfigConstructor(KVConfig{}, noError(KVFormat)),
+		"logfmt":      ConfigConstructor(KVConfig{Logfmt: true}, noError(KVFormat)),
+		"console":     ConfigConstructor(ConsoleConfig{}, noError(ConsoleFormat)),
+		"template":    ConfigConstructor(TemplateConfig{}, TemplateFormat),
+		"journald":    ConfigConstructor(JournaldConfig{}, noError(JournaldFormat)),
+		"gelf":        ConfigConstructor(GELFConfig{}, noError(GELFFormat)),
+		"ecs":         ConfigConstructor(ECSConfig{}, noError(ECSFormat)),
+		"otel":        ConfigConstructor(OTelConfig{}, noError(OTelFormat)),
+		"cbor":        ConfigConstructor(JSONConfig{}, noError(CBORFormat)),
+		"msgpack":     ConfigConstructor(JSONConfig{}, noError(MsgPackFormat)),
+		"cef":         ConfigConstructor(CEFConfig{}, noError(CEFFormat)),
+		"leef":        ConfigConstructor(LEEFConfig{}, noError(LEEFFormat)),
+		"gcp":         ConfigConstructor(GCPConfig{}, noError(GCPFormat)),
+		"emf":         ConfigConstructor(EMFConfig{}, noError(EMFFormat)),
+	},
+}
+
+func init() {
+	// Namespaced loggers keep these at the top level, where the formats look for them.
+	logf.ReserveProps(append([]string{OTEL_TRACE_ID, OTEL_SPAN_ID, CEF_SIGNATURE_ID, LEEF_EVENT_ID}, syslogHeaders...)...)
+}
+
+// Register adds a format to the registry, so Lookup can build it by name.
+// It returns DuplicateFormatError if the name is taken, including by a built-in format.
+func Register(name string, ctor Constructor) error {
+	if name == "" || ctor == nil {
+		return InvalidFormatError
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.formats[name]; ok {
+		return fmt.Errorf("%w: %s", DuplicateFormatError, name)
+	}
+	registry.formats[name] = ctor
+	return nil
+}
+
+// Lookup builds the named format with opts. Options are the format's config fields in
+// snake_case, as described by DecodeOptions:
+//
+//	format, err := formats.Lookup("syslog5424", map[string]any{"app_name": "api", "with_props": "json"})
+//
+// Unknown names return UnknownFormatError, and bad options return an *OptionError.
+// The syslog formats take with_props as kv, json, or ignore.
+func Lookup(name string, opts map[string]any) (logf.Formatter, error) {
+	registry.RLock()
+	ctor, ok := registry.formats[name]
+	registry.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", UnknownFormatError, name)
+	}
+	format, err := ctor(opts)
+	var optErr *OptionError
+	if errors.As(err, &optErr) && optErr.Format == "" {
+		optErr.Format = name
+	}
+	return format, err
+}
+
+// Registered returns the names of all registered formats, sorted.
+func Registered() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(registry.formats))
+	for name := range registry.formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ConfigConstructor returns a Constructor that decodes options into a copy of conf with
+// DecodeOptions, then calls build. Options that aren't set keep their value from conf.
+func ConfigConstructor[C any](conf C, build func(C) (logf.Formatter, error)) Constructor {
+	return func(opts Options) (logf.Formatter, error) {
+		conf := conf
+		if err := DecodeOptions(opts, &conf); err != nil {
+			return nil, err
+		}
+		return build(conf)
+	}
+}
+
+func noError[C any](build func(C) logf.Formatter) func(C) (logf.Formatter, error) {
+	return func(conf C) (logf.Formatter, error) {
+		return build(conf), nil
+	}
+}
+
+var syslogWithProps = map[string]func(string, logf.PropsView) string{
+	"kv":     SyslogKV,
+	"json":   SyslogJSON,
+	"ignore": SyslogIgnore,
+}
+
+func syslogConstructor(build func(SyslogConfig) logf.Formatter) Constructor {
+	return func(opts Options) (logf.Formatter, error) {
+		conf := SyslogConfig{}
+		opts = maps.Clone(opts)
+		if value, ok := opts["with_props"]; ok {
+			delete(opts, "with_props")
+			name, _ := value.(string)
+			if conf.WithProps = syslogWithProps[name]; conf.WithProps == nil {
+				return nil, &OptionError{Option: "with_props", Err: fmt.Errorf("%w: want kv, json, or ignore", OptionValueError)}
+			}
+		}
+		if err := DecodeOptions(opts, &conf); err != nil {
+			return nil, err
+		}
+		return build(conf), nil
+	}
+}
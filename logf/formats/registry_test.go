@@ -0,0 +1,199 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+	"github.com/decentplatforms/appkit/logf/testhelp"
+)
+
+func TestOptionName(t *testing.T) {
+	for field, wanted := range map[string]string{
+		"TimeFormat":         "time_format",
+		"UseISO8601":         "use_iso8601",
+		"ProcID":             "proc_id",
+		"LevelStrKey":        "level_str_key",
+		"ShortMessageLength": "short_message_length",
+		"URL":                "url",
+		"HTTPHeaders":        "http_headers",
+	} {
+		if name := OptionName(field); name != wanted {
+			t.Errorf("%s: wanted %s, got %s", field, wanted, name)
+		}
+	}
+}
+
+type optionsTestConfig struct {
+	JSONConfig
+	Count    int
+	Small    uint8
+	Ratio    float64
+	Delim    rune
+	Wait     time.Duration
+	Color    ConsoleColor
+	Tags     []string
+	Labels   map[string]string
+	Metrics  []EMFMetric
+	Callback func()
+}
+
+func TestDecodeOptions(t *testing.T) {
+	var raw map[string]any
+	err := json.Unmarshal([]byte(`{
+		"time_format": "15:04",
+		"flatten": true,
+		"count": 3,
+		"small": 255,
+		"ratio": 0.5,
+		"delim": "^",
+		"wait": "1.5s",
+		"color": "always",
+		"tags": ["a", "b"],
+		"labels": {"env": "prod"},
+		"metrics": [{"name": "latency", "unit": "Milliseconds"}],
+		"indent": null
+	}`), &raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := optionsTestConfig{Count: 1}
+	if err := DecodeOptions(raw, &conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.TimeFormat != "15:04" || !conf.Flatten || conf.Count != 3 || conf.Small != 255 || conf.Ratio != 0.5 ||
+		conf.Delim != '^' || conf.Wait != 1500*time.Millisecond || conf.Color != ColorAlways ||
+		strings.Join(conf.Tags, ",") != "a,b" || conf.Labels["env"] != "prod" ||
+		len(conf.Metrics) != 1 || conf.Metrics[0].Unit != "Milliseconds" || conf.Indent != "" {
+		t.Errorf("wrong config: %+v", conf)
+	}
+}
+
+func TestDecodeOptionsErrors(t *testing.T) {
+	tests := map[string]map[string]any{
+		"unknown":        {"nope": 1},
+		"func":           {"callback": "x"},
+		"type":           {"count": "3"},
+		"fraction":       {"count": 1.5},
+		"overflow":       {"small": 256},
+		"negative":       {"small": -1},
+		"duration":       {"wait": "soon"},
+		"color":          {"color": "sometimes"},
+		"nested":         {"metrics": []any{map[string]any{"name": "a"}, map[string]any{"unit": 1}}},
+		"nested_unknown": {"metrics": []any{map[string]any{"bad": "a"}}},
+		"list":           {"tags": "a"},
+	}
+	expects := testhelp.ResultsMap{
+		"unknown":        "nope unknown option",
+		"func":           "callback unknown option",
+		"type":           "count wrong option type",
+		"fraction":       "count bad option value",
+		"overflow":       "small bad option value",
+		"negative":       "small bad option value",
+		"duration":       "wait bad option value",
+		"color":          "color bad option value",
+		"nested":         "metrics[1].unit wrong option type",
+		"nested_unknown": "metrics[0].bad unknown option",
+		"list":           "tags wrong option type",
+	}
+	res := testhelp.ResultsMap{}
+	for name, opts := range tests {
+		err := DecodeOptions(opts, &optionsTestConfig{})
+		var optErr *OptionError
+		if !errors.As(err, &optErr) {
+			t.Errorf("%s: expected *OptionError, got %v", name, err)
+			continue
+		}
+		for _, sentinel := range []error{UnknownOptionError, OptionTypeError, OptionValueError} {
+			if errors.Is(err, sentinel) {
+				res[name] = optErr.Option + " " + sentinel.Error()
+			}
+		}
+	}
+	if err := testhelp.ValidateResults(res, expects); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	format, err := Lookup("syslog5424", map[string]any{"app_name": "api", "hostname": "host", "with_props": "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := format.FormatAndNormalize(logf.Error, "test log", logf.NewProps(logf.Int("a", 1)))
+	if !strings.Contains(out, " host api ") || !strings.HasSuffix(out, ` test log {"a":1}`+"\n") {
+		t.Errorf("wrong syslog output: %q", out)
+	}
+
+	for _, name := range Registered() {
+		if _, err := Lookup(name, nil); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	if _, err := Lookup("nope", nil); !errors.Is(err, UnknownFormatError) {
+		t.Errorf("expected UnknownFormatError, got %v", err)
+	}
+	_, err = Lookup("json", map[string]any{"indent": 2})
+	var optErr *OptionError
+	if !errors.As(err, &optErr) || optErr.Format != "json" || err.Error() != "format json: option indent: wrong option type: can't use int for string" {
+		t.Errorf("wrong option error: %v", err)
+	}
+	if _, err := Lookup("syslog3164", map[string]any{"with_props": "xml"}); !errors.Is(err, OptionValueError) {
+		t.Errorf("expected OptionValueError, got %v", err)
+	}
+	if _, err := Lookup("template", map[string]any{"template": "{{.Nope}}"}); !errors.Is(err, TemplateSyntaxError) {
+		t.Errorf("expected TemplateSyntaxError, got %v", err)
+	}
+
+	format, err = Lookup("json", map[string]any{"level_names": "python", "timestamp_key": "-"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := format.FormatAndNormalize(logf.Warning, "test log", logf.NewProps()); out != `{"level":4,"level_str":"WARNING","message":"test log"}`+"\n" {
+		t.Errorf("level_names wasn't used: %q", out)
+	}
+	if _, err := Lookup("json", map[string]any{"level_names": "klingon"}); !errors.Is(err, OptionValueError) {
+		t.Errorf("expected OptionValueError, got %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	ctor := ConfigConstructor(KVConfig{UseSingleQuotes: true}, noError(KVFormat))
+	if err := Register("test_kv", ctor); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register("test_kv", ctor); !errors.Is(err, DuplicateFormatError) {
+		t.Errorf("expected DuplicateFormatError, got %v", err)
+	}
+	if err := Register("json", ctor); !errors.Is(err, DuplicateFormatError) {
+		t.Errorf("expected DuplicateFormatError, got %v", err)
+	}
+	if err := Register("", ctor); err != InvalidFormatError {
+		t.Errorf("expected InvalidFormatError, got %v", err)
+	}
+	format, err := Lookup("test_kv", map[string]any{"time_format": "2006"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := format.FormatAndNormalize(logf.Error, "test log", logf.NewProps()); !strings.Contains(out, "message='test log'") {
+		t.Errorf("wrong preset: %q", out)
+	}
+}
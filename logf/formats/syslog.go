@@ -15,9 +15,10 @@
 package formats
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/decentplatforms/appkit/logf"
@@ -28,47 +29,74 @@ type SDElement struct {
 }
 
 const (
-	SYSLOG_HOSTNAME = string("log_syslog_hostname")
-	SYSLOG_APPNAME  = string("log_syslog_appname")
-	SYSLOG_TAG      = string("log_syslog_TAG")
+	SYSLOG_HOSTNAME  = string("log_syslog_hostname")
+	SYSLOG_APPNAME   = string("log_syslog_appname")
+	SYSLOG_TAG       = string("log_syslog_TAG")
+	SYSLOG_PROCID    = string("log_syslog_procid")
+	SYSLOG_TIMESTAMP = string("log_syslog_timestamp")
+	SYSLOG_FACILITY  = string("log_syslog_facility")
 )
 
+// syslogHeaders are the props syslog formats consume as headers. They're omitted from WithProps.
+var syslogHeaders = []string{SYSLOG_HOSTNAME, SYSLOG_APPNAME, SYSLOG_TAG, SYSLOG_PROCID, SYSLOG_TIMESTAMP, SYSLOG_FACILITY}
+
+// syslogTime returns the SYSLOG_TIMESTAMP prop if it's a time.Time, like the original time of a
+// relayed message, or the current time. It's in UTC.
+func syslogTime(props logf.PropGetter) time.Time {
+	if ts, ok := props.Get(SYSLOG_TIMESTAMP).(time.Time); ok && !ts.IsZero() {
+		return ts.UTC()
+	}
+	return time.Now().UTC()
+}
+
+// syslogFacility returns the SYSLOG_FACILITY prop if it's a facility from 0 to 23, or def.
+// Unlike SyslogConfig.Facility, the prop may be 0 (kern), so relayed kernel messages keep it.
+func syslogFacility(props logf.PropGetter, def int) int {
+	if facility := logf.GetInt(props, SYSLOG_FACILITY, -1); facility >= 0 && facility <= 23 {
+		return facility
+	}
+	return def
+}
+
 // SyslogConfig sets default values for SyslogXFormat loggers.
 // Providing SYSLOG_X props overrides these headers; otherwise the values from config are used.
 // Usage notes:
 //   - Tag is used as MSGID in 5424
+//   - ProcID replaces the process ID in 5424; in 3164 it's appended to the tag as TAG[ProcID]
 //   - UseISO8601 only applies to RFC 3164; rfc5424 specifies RFC3339 time
 //   - You may not set Facility to 0
 //   - WithProps uses formats.SyslogKV by default.
+//   - EnterpriseID is appended to the SD-IDs of groups in 5424 as name@EnterpriseID, which
+//     RFC 5424 requires for SD-IDs that aren't registered with IANA
 type SyslogConfig struct {
-	Hostname   string
-	AppName    string
-	Tag        string
-	Facility   int
-	UseISO8601 bool
-	WithProps  func(string, *logf.Props) string
+	Hostname     string
+	AppName      string
+	Tag          string
+	ProcID       string
+	Facility     int
+	UseISO8601   bool
+	WithProps    func(string, logf.PropsView) string
+	EnterpriseID string
 }
 
 // SyslogJSON is an option for SyslogConfig.WithProps.
-// It appends spare props as JSON to the syslog message.
-func SyslogJSON(msg string, props *logf.Props) string {
+// It appends spare props as JSON to the syslog message, encoded like JSONFormat's props but with
+// keys sorted.
+func SyslogJSON(msg string, props logf.PropsView) string {
 	spareProps := props.Map()
 	if len(spareProps) > 0 {
-		raw, err := json.Marshal(spareProps)
-		if err == nil {
-			msg = msg + " " + string(raw)
-		}
+		msg = msg + " " + string(appendJSON(nil, normalize(spareProps)))
 	}
 	return msg
 }
 
-func SyslogKV(msg string, props *logf.Props) string {
+func SyslogKV(msg string, props logf.PropsView) string {
 	return msg + " " + formatProps(props, false)
 }
 
 // SyslogIgnore is an option for SyslogConfig.WithProps.
 // It ignores spare props, leaving the message as-is.
-func SyslogIgnore(msg string, props *logf.Props) string {
+func SyslogIgnore(msg string, props logf.PropsView) string {
 	return msg
 }
 
@@ -96,23 +124,67 @@ func (conf SyslogConfig) withDefaults() SyslogConfig {
 	return conf
 }
 
+// procID returns the PROCID for a message: the SYSLOG_PROCID prop if it's a string or integer, or
+// def. PROCID is at most 128 characters of printable ASCII, without spaces, so other characters
+// are replaced with underscores and longer values are truncated.
+func procID(props logf.PropsView, def string) string {
+	id := def
+	switch v := props.Get(SYSLOG_PROCID).(type) {
+	case string:
+		if v != "" {
+			id = v
+		}
+	case int:
+		id = strconv.Itoa(v)
+	case int64:
+		id = strconv.FormatInt(v, 10)
+	case uint64:
+		id = strconv.FormatUint(v, 10)
+	}
+	return printUSASCII(id, 128)
+}
+
+// printUSASCII replaces characters other than printable, non-space ASCII with _, and truncates s
+// to limit characters, as RFC 5424 requires for header fields.
+func printUSASCII(s string, limit int) string {
+	out := make([]byte, 0, min(len(s), limit))
+	for _, r := range s {
+		if len(out) == limit {
+			break
+		}
+		if r <= ' ' || r > '~' {
+			r = '_'
+		}
+		out = append(out, byte(r))
+	}
+	return string(out)
+}
+
 // Syslog5424Format provides the syslog format (RFC5424) with the following conventions:
-//   - Timestamps are RFC3339 in UTC
+//   - Timestamp is the log.SYSLOG_TIMESTAMP prop or the current time, RFC3339 in UTC
 //   - Hostname is the log.SYSLOG_HOSTNAME prop, conf.Hostname, the machine's hostname at process start, or NILVALUE
 //   - App name is log.SYSLOG_APPNAME prop, conf.AppName, or log
-//   - Process ID is the application's process ID
+//   - Process ID is the log.SYSLOG_PROCID prop, conf.ProcID, or the application's process ID at format creation
 //   - Message ID is the log.SYSLOG_MSGID prop, conf.MsgId, or log
-//   - Facility is conf.Facility or User (1) and may not be 0
+//   - Facility is the log.SYSLOG_FACILITY prop, conf.Facility, or User (1). conf.Facility may not be 0.
 //   - Version is 1
-//   - Structured Data is -. Support for this field is planned.
+//   - Structured Data has an SD-ELEMENT for each logf.Group prop, with the group's name as its
+//     SD-ID and its props as SD-PARAMs. Nested groups are flattened to dotted PARAM-NAMEs, and
+//     groups with the same name are merged. With no groups, it's -.
+//
+// Groups are left out of the props passed to conf.WithProps.
 func Syslog5424Format(conf SyslogConfig) logf.Formatter {
 	conf = conf.withDefaults()
-	return func(level logf.LogLevel, msg string, props *logf.Props) string {
-		var timestamp, hostname, appname, msgid, structured string
-		var facility, pri, version, pid int
+	defaultProcID := conf.ProcID
+	if defaultProcID == "" {
+		defaultProcID = strconv.Itoa(os.Getpid())
+	}
+	return func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		var timestamp, hostname, appname, procid, msgid string
+		var facility, pri, version int
 		var ok bool
 
-		timestamp = time.Now().UTC().Format(time.RFC3339)
+		timestamp = syslogTime(props).Format(time.RFC3339)
 
 		if hostname, ok = props.Get(SYSLOG_HOSTNAME).(string); ok {
 		} else {
@@ -129,38 +201,131 @@ func Syslog5424Format(conf SyslogConfig) logf.Formatter {
 			msgid = conf.Tag
 		}
 
-		structured = "-"
+		structured, groups := structuredData(props, conf.EnterpriseID)
 
-		facility = conf.Facility
+		facility = syslogFacility(props, conf.Facility)
 
 		pri = 8*facility + int(level)
 		version = 1
-		pid = os.Getpid()
+		procid = procID(props, defaultProcID)
+
+		msg = conf.WithProps(msg, props.Without(syslogHeaders...).Without(groups...))
+
+		return fmt.Sprintf("<%d>%d %s %s %s %s %s %s %s", pri, version, timestamp, hostname, appname, procid, msgid, structured, msg)
+	}
+}
 
-		props.Delete(SYSLOG_HOSTNAME, SYSLOG_APPNAME, SYSLOG_TAG)
-		msg = conf.WithProps(msg, props)
+// sdValueEscaper escapes the characters RFC 5424 requires to be escaped in a PARAM-VALUE.
+var sdValueEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
 
-		return fmt.Sprintf("<%d>%d %s %s %s %d %s %s %s", pri, version, timestamp, hostname, appname, pid, msgid, structured, msg)
+// structuredData returns STRUCTURED-DATA with an SD-ELEMENT for each logf.Group prop, and the
+// names of the groups it used. It returns NILVALUE if there are no groups.
+func structuredData(props logf.PropsView, enterpriseID string) (string, []string) {
+	ids := []string{}
+	params := map[string][]logf.Prop{}
+	groups := []string{}
+	for _, prop := range props.Slice() {
+		group, ok := prop.Value.(logf.PropGroup)
+		if !ok {
+			continue
+		}
+		groups = append(groups, prop.Name)
+		id := sdID(prop.Name, enterpriseID)
+		if _, ok := params[id]; !ok {
+			ids = append(ids, id)
+		}
+		params[id] = append(params[id], flattenGroups(group)...)
+	}
+	if len(ids) == 0 {
+		return "-", nil
+	}
+	var out strings.Builder
+	for _, id := range ids {
+		out.WriteByte('[')
+		out.WriteString(id)
+		for _, param := range params[id] {
+			out.WriteByte(' ')
+			out.WriteString(sdName(param.Name, 32))
+			out.WriteString(`="`)
+			out.WriteString(sdValueEscaper.Replace(textValue(param.Value)))
+			out.WriteByte('"')
+		}
+		out.WriteByte(']')
+	}
+	return out.String(), groups
+}
+
+// sdID returns the SD-ID for a group: its name, with @enterpriseID if set, in at most 32 characters.
+func sdID(name string, enterpriseID string) string {
+	if enterpriseID == "" {
+		return sdName(name, 32)
 	}
+	suffix := "@" + sdName(enterpriseID, 31)
+	return sdName(strings.ReplaceAll(name, "@", "_"), 32-len(suffix)) + suffix
+}
+
+// sdName makes name a valid SD-NAME of at most limit characters: printable ASCII other than '=',
+// ' ', ']', and '"'. Other characters are replaced with _, and an empty name becomes _.
+func sdName(name string, limit int) string {
+	if name == "" || limit < 1 {
+		return "_"
+	}
+	out := []byte{}
+	for _, r := range name {
+		if len(out) == limit {
+			break
+		}
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			r = '_'
+		}
+		out = append(out, byte(r))
+	}
+	return string(out)
+}
+
+// Syslog5424Wrap provides Syslog5424Format headers around the output of another format, like
+// CEFFormat or LEEFFormat, for collectors that expect syslog framing.
+// inner is used as the syslog MSG, so conf.WithProps is ignored. SYSLOG_X props are used for the
+// headers, and logf.Group props for STRUCTURED-DATA, so neither is passed to inner.
+func Syslog5424Wrap(conf SyslogConfig, inner logf.Formatter) logf.Formatter {
+	conf.WithProps = SyslogIgnore
+	header := Syslog5424Format(conf)
+	return func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		innerProps := props.Without(syslogHeaders...).Without(groupNames(props)...)
+		return header(level, inner(level, msg, innerProps), props)
+	}
+}
+
+// groupNames returns the names of the logf.Group props in props.
+func groupNames(props logf.PropsView) []string {
+	names := []string{}
+	for _, prop := range props.Slice() {
+		if _, ok := prop.Value.(logf.PropGroup); ok {
+			names = append(names, prop.Name)
+		}
+	}
+	return names
 }
 
 // Syslog3164Format provides the syslog format (RFC3164) with the following conventions:
-//   - Timestamps are time.Stamp in UTC (Mmm dd hh:mm:ss)
+//   - Timestamp is the log.SYSLOG_TIMESTAMP prop or the current time, as time.Stamp in UTC (Mmm dd hh:mm:ss)
 //   - Hostname is the log.SYSLOG_HOSTNAME prop, the machine's hostname at process start, or NILVALUE
 //   - Tag is the log.SYSLOG_TAG prop or log
-//   - Facility is User (1)
+//   - If the log.SYSLOG_PROCID prop or conf.ProcID is set, it's appended to the tag as TAG[PID]
+//   - Facility is the log.SYSLOG_FACILITY prop, conf.Facility, or User (1)
 //
 // Spare props are appended to MSG as JSON.
 func Syslog3164Format(conf SyslogConfig) logf.Formatter {
 	conf = conf.withDefaults()
-	return func(level logf.LogLevel, msg string, props *logf.Props) string {
+	return func(level logf.LogLevel, msg string, props logf.PropsView) string {
 		var timestamp, hostname, tag string
 		var facility, pri int
 		var ok bool
 
-		timestamp = time.Now().UTC().Format(time.Stamp)
+		now := syslogTime(props)
+		timestamp = now.Format(time.Stamp)
 		if conf.UseISO8601 {
-			timestamp = time.Now().UTC().Format(time.RFC3339)
+			timestamp = now.Format(time.RFC3339)
 		}
 
 		if hostname, ok = props.Get(SYSLOG_HOSTNAME).(string); ok {
@@ -173,12 +338,15 @@ func Syslog3164Format(conf SyslogConfig) logf.Formatter {
 			tag = conf.Tag
 		}
 
-		facility = conf.Facility
+		if procid := procID(props, conf.ProcID); procid != "" {
+			tag = tag + "[" + procid + "]"
+		}
+
+		facility = syslogFacility(props, conf.Facility)
 
 		pri = 8*facility + int(level)
 
-		props.Delete(SYSLOG_HOSTNAME, SYSLOG_APPNAME, SYSLOG_TAG)
-		msg = conf.WithProps(msg, props)
+		msg = conf.WithProps(msg, props.Without(syslogHeaders...))
 
 		return fmt.Sprintf("<%d>%s %s %s: %s", pri, timestamp, hostname, tag, msg)
 	}
@@ -3,7 +3,10 @@ package formats
 import (
 	"errors"
 	"fmt"
+	"os"
 	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/decentplatforms/appkit/logf"
@@ -30,17 +33,28 @@ var syslog_formats = map[string]logf.Formatter{
 		Tag:       "test-log",
 		WithProps: SyslogIgnore,
 	}),
+	"syslog_rfc3164.procid": Syslog3164Format(SyslogConfig{
+		Hostname: "test-host",
+		Tag:      "test-log",
+		ProcID:   "pool",
+	}),
 	"syslog_rfc5424": Syslog5424Format(SyslogConfig{
 		Hostname: "test-host",
 		AppName:  "test-app",
 		Tag:      "test-log",
 	}),
+	"syslog_rfc5424.procid": Syslog5424Format(SyslogConfig{
+		Hostname: "test-host",
+		AppName:  "test-app",
+		Tag:      "test-log",
+		ProcID:   "pool",
+	}),
 }
 
 var syslog_regexes = map[string]*regexp.Regexp{
 	"syslog_rfc3164":            regexp.MustCompile(`(?P<pri><\d+>)(?P<timestamp>[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}) (?P<hostname>\S+) (?P<tag>[^:\s]+): (?P<message>.+)`),
 	"syslog_rfc3164.timedetail": regexp.MustCompile(`(?P<pri><\d+>)(?P<timestamp>\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z) (?P<hostname>\S+) (?P<tag>[^:\s]+): (?P<message>.+)`),
-	"syslog_rfc5424":            regexp.MustCompile(`(?P<pri><\d+>)(?P<version>\d+) (?P<timestamp>\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:Z|[\+-]\d{2}:\d{2})) (?P<hostname>\S+) (?P<appname>\S+) (?P<pid>\d+) (?P<msgid>\S+) (?P<structured>\S+) (?P<message>.+)`),
+	"syslog_rfc5424":            regexp.MustCompile(`(?P<pri><\d+>)(?P<version>\d+) (?P<timestamp>\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:Z|[\+-]\d{2}:\d{2})) (?P<hostname>\S+) (?P<appname>\S+) (?P<pid>\S+) (?P<msgid>\S+) (?P<structured>\S+) (?P<message>.+)`),
 }
 
 var syslog_expects = map[string]testhelp.ResultsMap{
@@ -62,11 +76,28 @@ var syslog_expects = map[string]testhelp.ResultsMap{
 		"tag":      "test-log",
 		"message":  `test log`,
 	},
+	"syslog_rfc3164.procid": {
+		"pri":      "<14>",
+		"hostname": "test-host",
+		"tag":      "test-log[pool]",
+		"message":  `test log detail="testing format using regex"`,
+	},
 	"syslog_rfc5424": {
 		"pri":        "<14>",
 		"version":    "1",
 		"hostname":   "test-host",
 		"appname":    "test-app",
+		"pid":        strconv.Itoa(os.Getpid()),
+		"msgid":      "test-log",
+		"structured": "-",
+		"message":    `test log detail="testing format using regex"`,
+	},
+	"syslog_rfc5424.procid": {
+		"pri":        "<14>",
+		"version":    "1",
+		"hostname":   "test-host",
+		"appname":    "test-app",
+		"pid":        "pool",
 		"msgid":      "test-log",
 		"structured": "-",
 		"message":    `test log detail="testing format using regex"`,
@@ -74,8 +105,11 @@ var syslog_expects = map[string]testhelp.ResultsMap{
 }
 
 var syslog_custom_props = map[string][]logf.Prop{
-	"syslog_rfc3164": {logf.String(SYSLOG_HOSTNAME, "custom-host"), logf.String(SYSLOG_TAG, "custom-log")},
-	"syslog_rfc5424": {logf.String(SYSLOG_HOSTNAME, "custom-host"), logf.String(SYSLOG_APPNAME, "custom-app"), logf.String(SYSLOG_TAG, "custom-log")},
+	"syslog_rfc3164":        {logf.String(SYSLOG_HOSTNAME, "custom-host"), logf.String(SYSLOG_TAG, "custom-log")},
+	"syslog_rfc3164.procid": {logf.String(SYSLOG_HOSTNAME, "custom-host"), logf.String(SYSLOG_TAG, "custom-log"), logf.Int(SYSLOG_PROCID, 3)},
+	"syslog_rfc5424":        {logf.String(SYSLOG_HOSTNAME, "custom-host"), logf.String(SYSLOG_APPNAME, "custom-app"), logf.String(SYSLOG_TAG, "custom-log")},
+	"syslog_rfc5424.procid": {logf.String(SYSLOG_HOSTNAME, "custom-host"), logf.String(SYSLOG_APPNAME, "custom-app"), logf.String(SYSLOG_TAG, "custom-log"),
+		logf.String(SYSLOG_PROCID, "worker-3")},
 }
 
 var syslog_custom_expects = map[string]testhelp.ResultsMap{
@@ -97,11 +131,28 @@ var syslog_custom_expects = map[string]testhelp.ResultsMap{
 		"tag":      "custom-log",
 		"message":  `test log`,
 	},
+	"syslog_rfc3164.procid": {
+		"pri":      "<14>",
+		"hostname": "custom-host",
+		"tag":      "custom-log[3]",
+		"message":  `test log detail="testing with custom props"`,
+	},
 	"syslog_rfc5424": {
 		"pri":        "<14>",
 		"version":    "1",
 		"hostname":   "custom-host",
 		"appname":    "custom-app",
+		"pid":        strconv.Itoa(os.Getpid()),
+		"msgid":      "custom-log",
+		"structured": "-",
+		"message":    `test log detail="testing with custom props"`,
+	},
+	"syslog_rfc5424.procid": {
+		"pri":        "<14>",
+		"version":    "1",
+		"hostname":   "custom-host",
+		"appname":    "custom-app",
+		"pid":        "worker-3",
 		"msgid":      "custom-log",
 		"structured": "-",
 		"message":    `test log detail="testing with custom props"`,
@@ -177,3 +228,63 @@ func TestSyslog(t *testing.T) {
 		})
 	}
 }
+
+func TestSyslogSharedProps(t *testing.T) {
+	props := logf.NewProps(append(testhelp.GetTestOption(syslog_custom_props, "syslog_rfc5424", nil),
+		logf.String("detail", "testing shared props"))...)
+	for _, name := range []string{"syslog_rfc5424", "syslog_rfc3164"} {
+		out := syslog_formats[name].FormatAndNormalize(logf.Informational, "test log", props)
+		res, err := results(out, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wanted := testhelp.ResultsMap{"message": `test log detail="testing shared props"`}
+		for k, v := range testhelp.GetTestOption(syslog_custom_expects, name, nil) {
+			if k != "message" {
+				wanted[k] = v
+			}
+		}
+		err = testhelp.ValidateResults(res, wanted)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if host := props.Get(SYSLOG_HOSTNAME); host != "custom-host" {
+		t.Errorf("formatting changed props: wanted hostname custom-host, got %v", host)
+	}
+	if ct := len(props.Slice()); ct != 4 {
+		t.Errorf("formatting changed props: wanted 4 props, got %d", ct)
+	}
+}
+
+func TestSyslogProcID(t *testing.T) {
+	procid := func(conf SyslogConfig, props ...logf.Prop) string {
+		out := Syslog5424Format(conf).FormatAndNormalize(logf.Informational, "test log", logf.NewProps(props...))
+		res, err := results(out, "syslog_rfc5424")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res["pid"]
+	}
+	long := strings.Repeat("é", 100)
+	res := testhelp.ResultsMap{
+		"conf":      procid(SyslogConfig{ProcID: "pool\tworker 1"}),
+		"prop":      procid(SyslogConfig{}, logf.String(SYSLOG_PROCID, "wörker\n3")),
+		"uint":      procid(SyslogConfig{}, logf.UInt(SYSLOG_PROCID, uint(7))),
+		"long_conf": procid(SyslogConfig{ProcID: long}),
+		"long_prop": procid(SyslogConfig{}, logf.String(SYSLOG_PROCID, long)),
+	}
+	wanted := testhelp.ResultsMap{
+		"conf":      "pool_worker_1",
+		"prop":      "w_rker_3",
+		"uint":      "7",
+		"long_conf": strings.Repeat("_", 100),
+		"long_prop": strings.Repeat("_", 100),
+	}
+	if err := testhelp.ValidateResults(res, wanted); err != nil {
+		t.Error(err)
+	}
+	if id := procID(logf.NewProps(logf.String(SYSLOG_PROCID, strings.Repeat("a", 200))).View(), ""); len(id) != 128 {
+		t.Errorf("wanted 128 characters, got %d", len(id))
+	}
+}
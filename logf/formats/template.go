@@ -0,0 +1,280 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+var TemplateSyntaxError = errors.New("invalid log template")
+
+// TemplateConfig sets default values for TemplateFormat.
+// Usage notes:
+//   - Template is either a text/template, or a layout of {field} placeholders. Templates without {{
+//     are layouts. It defaults to "{time} {level_str} {message} {props}".
+//   - TimeFormat is used for {time} and the timefmt func's default, and defaults to time.RFC3339
+//   - Funcs adds to or overrides the template funcs. It isn't used by layouts.
+//   - LevelNames names levels for level_str, instead of the logger's Config.LevelNames
+type TemplateConfig struct {
+	Template   string
+	TimeFormat string
+	Funcs      template.FuncMap
+	LevelNames *logf.LevelNames
+}
+
+func (conf TemplateConfig) withDefaults() TemplateConfig {
+	if conf.Template == "" {
+		conf.Template = "{time} {level_str} {message} {props}"
+	}
+	if conf.TimeFormat == "" {
+		conf.TimeFormat = time.RFC3339
+	}
+	return conf
+}
+
+// TemplateRecord is the data passed to templates.
+// Time is the current time in UTC. Props are in order, so {{range .Props}} ranges over them as
+// written, and Prop looks one up by name.
+type TemplateRecord struct {
+	Level    logf.LogLevel
+	LevelStr string
+	Time     time.Time
+	Message  string
+	Props    []logf.Prop
+
+	view logf.PropsView
+}
+
+// Prop returns the named prop's value, or "" if there's no such prop.
+func (rec TemplateRecord) Prop(name string) any {
+	if v := rec.view.Get(name); v != nil {
+		return v
+	}
+	return ""
+}
+
+// TemplateFuncs are the funcs available to every template:
+//   - timefmt LAYOUT TIME formats a time.Time; {{timefmt "15:04:05" .Time}}
+//   - pad WIDTH VALUE pads a value with spaces on the right to WIDTH characters, or on the left
+//     if WIDTH is negative
+//   - json VALUE writes a value as JSON. []logf.Prop is written as an object, in order,
+//     like JSONFormat's props.
+//   - logfmt VALUE writes a value as a logfmt value. []logf.Prop and logf.PropGroup are written as
+//     key=value pairs, with groups flattened to dotted keys like http.method.
+//   - utc TIME converts a time.Time to UTC
+func TemplateFuncs(conf TemplateConfig) template.FuncMap {
+	conf = conf.withDefaults()
+	return template.FuncMap{
+		"timefmt": func(layout string, t time.Time) string {
+			if layout == "" {
+				layout = conf.TimeFormat
+			}
+			return t.Format(layout)
+		},
+		"pad":    templatePad,
+		"json":   templateJSON,
+		"logfmt": templateLogfmt,
+		"utc": func(t time.Time) time.Time {
+			return t.UTC()
+		},
+	}
+}
+
+func templatePad(width int, value any) string {
+	str := fmt.Sprint(value)
+	left := width < 0
+	if left {
+		width = -width
+	}
+	n := width - utf8.RuneCountInString(str)
+	if n <= 0 {
+		return str
+	}
+	if left {
+		return strings.Repeat(" ", n) + str
+	}
+	return str + strings.Repeat(" ", n)
+}
+
+func templateJSON(value any) (string, error) {
+	if props, ok := value.([]logf.Prop); ok {
+		return string(appendJSON(nil, normalize(props))), nil
+	}
+	raw, err := json.Marshal(value)
+	return string(raw), err
+}
+
+func templateLogfmt(value any) string {
+	var props []logf.Prop
+	switch v := value.(type) {
+	case []logf.Prop:
+		props = v
+	case logf.PropGroup:
+		props = v
+	default:
+		return LogfmtValue(value)
+	}
+	var out strings.Builder
+	for i, prop := range flattenGroups(props) {
+		if i > 0 {
+			out.WriteByte(' ')
+		}
+		out.WriteString(LogfmtKey(prop.Name))
+		out.WriteByte('=')
+		out.WriteString(LogfmtValue(prop.Value))
+	}
+	return out.String()
+}
+
+// TemplateFormat provides a custom line layout, compiled and checked once when it's created.
+//
+// Layouts are a fast path for simple formats, like "{time} [{request_id}] {message} {props}":
+//   - {level} is the level number, and {level_str} is the level keyword
+//   - {time} is the current time in UTC, formatted with conf.TimeFormat
+//   - {message} is the log message
+//   - {props} is the props as logfmt key=value pairs, except for props with their own placeholder.
+//     Groups are written with dotted keys, like http.method.
+//   - Any other {name} is the value of the prop name, or nothing if it isn't set
+//
+// Templates are text/templates executed with a TemplateRecord, like
+// "{{.Time | timefmt \"15:04\"}} {{.LevelStr | pad 6}} {{.Message}} {{logfmt .Props}}", and can use
+// TemplateFuncs and conf.Funcs.
+//
+// If a template fails while formatting, its output so far is written with a template_error prop.
+func TemplateFormat(conf TemplateConfig) (logf.Formatter, error) {
+	conf = conf.withDefaults()
+	if !strings.Contains(conf.Template, "{{") {
+		return layoutFormat(conf)
+	}
+
+	funcs := TemplateFuncs(conf)
+	for name, fn := range conf.Funcs {
+		funcs[name] = fn
+	}
+	tmpl, err := template.New("logf").Funcs(funcs).Parse(conf.Template)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", TemplateSyntaxError, err)
+	}
+	// Check the template against a sample record, so mistakes like unknown fields are caught now
+	// instead of on every log.
+	sample := logf.NewProps()
+	defer sample.Return()
+	if err := tmpl.Execute(io.Discard, newTemplateRecord(conf.LevelNames, logf.Informational, "", sample.View())); err != nil {
+		return nil, fmt.Errorf("%w: %w", TemplateSyntaxError, err)
+	}
+
+	return func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		var out strings.Builder
+		if err := tmpl.Execute(&out, newTemplateRecord(conf.LevelNames, level, msg, props)); err != nil {
+			out.WriteString(" template_error=")
+			out.WriteString(LogfmtValue(err))
+		}
+		return out.String()
+	}, nil
+}
+
+func newTemplateRecord(names *logf.LevelNames, level logf.LogLevel, msg string, props logf.PropsView) TemplateRecord {
+	return TemplateRecord{
+		Level:    level,
+		LevelStr: levelName(names, level, props),
+		Time:     time.Now().UTC(),
+		Message:  msg,
+		Props:    props.Slice(),
+		view:     props,
+	}
+}
+
+// layoutSegment is a literal string, or a placeholder if field is set.
+type layoutSegment struct {
+	literal string
+	field   string
+}
+
+func parseLayout(layout string) ([]layoutSegment, error) {
+	segments := []layoutSegment{}
+	i := 0
+	for i < len(layout) {
+		start := strings.IndexByte(layout[i:], '{')
+		if start < 0 {
+			segments = append(segments, layoutSegment{literal: layout[i:]})
+			break
+		}
+		start += i
+		if start > i {
+			segments = append(segments, layoutSegment{literal: layout[i:start]})
+		}
+		end := strings.IndexByte(layout[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("%w: unclosed { at offset %d", TemplateSyntaxError, start)
+		}
+		end += start
+		field := layout[start+1 : end]
+		if field == "" || strings.ContainsAny(field, "{ ") {
+			return nil, fmt.Errorf("%w: bad placeholder %q at offset %d", TemplateSyntaxError, layout[start:end+1], start)
+		}
+		segments = append(segments, layoutSegment{field: field})
+		i = end + 1
+	}
+	return segments, nil
+}
+
+func layoutFormat(conf TemplateConfig) (logf.Formatter, error) {
+	segments, err := parseLayout(conf.Template)
+	if err != nil {
+		return nil, err
+	}
+	placed := []string{}
+	for _, seg := range segments {
+		switch seg.field {
+		case "", "level", "level_str", "time", "message", "props":
+		default:
+			placed = append(placed, seg.field)
+		}
+	}
+
+	return func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		var out strings.Builder
+		for _, seg := range segments {
+			switch seg.field {
+			case "":
+				out.WriteString(seg.literal)
+			case "level":
+				fmt.Fprint(&out, int(level))
+			case "level_str":
+				out.WriteString(levelName(conf.LevelNames, level, props))
+			case "time":
+				out.WriteString(time.Now().UTC().Format(conf.TimeFormat))
+			case "message":
+				out.WriteString(msg)
+			case "props":
+				out.WriteString(templateLogfmt(props.Without(placed...).Slice()))
+			default:
+				if v := props.Get(seg.field); v != nil {
+					fmt.Fprint(&out, v)
+				}
+			}
+		}
+		return out.String()
+	}, nil
+}
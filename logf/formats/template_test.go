@@ -0,0 +1,129 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/decentplatforms/appkit/logf"
+	"github.com/decentplatforms/appkit/logf/testhelp"
+)
+
+func TestTemplateFormat(t *testing.T) {
+	tests := map[string]TemplateConfig{
+		"layout":         {Template: "[{level_str}] {request_id}: {message} {props}"},
+		"layout_level":   {Template: "<{level}> {message} {missing}"},
+		"template":       {Template: `{{.LevelStr | pad 6}}|{{.Message}}|{{.Prop "request_id"}}|{{.Prop "missing"}}`},
+		"template_pad":   {Template: `{{.Message | json}}|{{pad -6 .Level}}|{{pad 2 "long"}}`},
+		"template_json":  {Template: `{{json .Props}}`},
+		"template_kv":    {Template: `{{logfmt .Message}} {{logfmt .Props}}`},
+		"template_range": {Template: `{{range .Props}}{{.Name}};{{end}}`},
+		"template_funcs": {Template: `{{shout .Message}}`, Funcs: template.FuncMap{"shout": strings.ToUpper}},
+		"template_time":  {Template: `{{if .Time.IsZero}}zero{{else}}{{timefmt "" .Time | len}}{{end}}`},
+	}
+	expects := testhelp.ResultsMap{
+		"layout":         `[warn] abc: test message num=1 detail="two words"`,
+		"layout_level":   `<4> test message`,
+		"template":       `warn  |test message|abc|`,
+		"template_pad":   `"test message"|  warn|long`,
+		"template_json":  `{"request_id":"abc","num":1,"detail":"two words"}`,
+		"template_kv":    `"test message" request_id=abc num=1 detail="two words"`,
+		"template_range": `request_id;num;detail;`,
+		"template_funcs": `TEST MESSAGE`,
+		"template_time":  `20`,
+	}
+	res := testhelp.ResultsMap{}
+	for name, conf := range tests {
+		format, err := TemplateFormat(conf)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		props := logf.NewProps(
+			logf.String("request_id", "abc"),
+			logf.Int("num", 1),
+			logf.String("detail", "two words"),
+		)
+		res[name] = strings.TrimSuffix(format.FormatAndNormalize(logf.Warning, "test message", props), "\n")
+	}
+	if err := testhelp.ValidateResults(res, expects); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTemplateGroups(t *testing.T) {
+	props := func() *logf.Props {
+		return logf.NewProps(
+			logf.Group("http", logf.String("method", "GET"), logf.Group("client", logf.String("ip", "10.0.0.1"))),
+			logf.Int("status", 200),
+		)
+	}
+	res := testhelp.ResultsMap{}
+	for name, tmpl := range map[string]string{
+		"layout":   "{message} {props}",
+		"template": "{{.Message}} {{logfmt .Props}}",
+		"group":    `{{.Message}} {{logfmt (.Prop "http")}}`,
+	} {
+		format, err := TemplateFormat(TemplateConfig{Template: tmpl})
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		res[name] = strings.TrimSuffix(format.FormatAndNormalize(logf.Warning, "done", props()), "\n")
+	}
+	wanted := testhelp.ResultsMap{
+		"layout":   "done http.method=GET http.client.ip=10.0.0.1 status=200",
+		"template": "done http.method=GET http.client.ip=10.0.0.1 status=200",
+		"group":    "done method=GET client.ip=10.0.0.1",
+	}
+	if err := testhelp.ValidateResults(res, wanted); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTemplateDefaults(t *testing.T) {
+	format, err := TemplateFormat(TemplateConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := format.FormatAndNormalize(logf.Error, "test message", logf.NewProps(logf.String("a", "b")))
+	if fields := strings.Fields(out); len(fields) != 5 || fields[1] != "err" || fields[4] != "a=b" {
+		t.Errorf("wrong default layout: %q", out)
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	for _, tmpl := range []string{
+		"{message",
+		"{} {message}",
+		"{{.Message",
+		"{{.Missing}}",
+		"{{nofunc .Message}}",
+	} {
+		if _, err := TemplateFormat(TemplateConfig{Template: tmpl}); !errors.Is(err, TemplateSyntaxError) {
+			t.Errorf("expected TemplateSyntaxError for %q, got %v", tmpl, err)
+		}
+	}
+
+	format, err := TemplateFormat(TemplateConfig{Template: `{{.Message}} {{json (.Prop "ch")}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := format.FormatAndNormalize(logf.Error, "test message", logf.NewProps(logf.Prop{Name: "ch", Value: make(chan int)}))
+	if !strings.HasPrefix(out, "test message ") || !strings.Contains(out, " template_error=") {
+		t.Errorf("expected template error: %q", out)
+	}
+}
@@ -0,0 +1,355 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formats
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+// field is one member of an object, which keeps its fields in order.
+type field struct {
+	name  string
+	value any
+}
+
+type object []field
+
+// rawJSON is the output of a json.Marshaler, or of encoding/json for structs.
+type rawJSON []byte
+
+// normalize reduces a prop value to one of nil, string, bool, int64, uint64, float64, []byte,
+// []any, object, or rawJSON, so every structured encoder writes values the same way:
+//   - time.Time is an RFC 3339 string with nanoseconds, in UTC
+//   - time.Duration is its String form, like 1.5s
+//   - *logf.ErrorValue is an object with message, type, and, if present, causes and stack. Other
+//     errors are their Error string.
+//   - logf.Frame is an object with function, file, and line
+//   - json.Marshaler is its JSON, and fmt.Stringer is its String form. Nil pointers are nil,
+//     without calling their methods.
+//   - []logf.Prop and logf.PropGroup are objects, in order, and maps are objects sorted by key.
+//     Props with the same name are written once, in the first one's place with the last one's
+//     value, like Props.Get returns.
+//   - Other slices and arrays are lists, and pointers are the value they point to
+//   - Structs are their encoding/json JSON, and anything else is formatted with fmt.Sprint
+//   - Values nested more than maxBinaryDepth deep, like in a map that contains itself, are
+//     replaced with maxDepthValue
+func normalize(value any) any {
+	return normalizeAt(value, 0)
+}
+
+// maxDepthValue replaces values nested too deeply to normalize.
+const maxDepthValue = "[max depth]"
+
+func normalizeAt(value any, depth int) any {
+	if depth > maxBinaryDepth {
+		return maxDepthValue
+	}
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return v
+	case bool:
+		return v
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case uint:
+		return uint64(v)
+	case uint8:
+		return uint64(v)
+	case uint16:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case uint64:
+		return v
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	case []byte:
+		return v
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.String()
+	case *logf.ErrorValue:
+		if v == nil {
+			return nil
+		}
+		obj := object{{name: "message", value: v.Message}, {name: "type", value: v.Type}}
+		if len(v.Causes) > 0 {
+			causes := make([]any, len(v.Causes))
+			for i, cause := range v.Causes {
+				causes[i] = normalizeAt(cause, depth+1)
+			}
+			obj = append(obj, field{name: "causes", value: causes})
+		}
+		if len(v.Stack) > 0 {
+			obj = append(obj, field{name: "stack", value: normalizeAt(v.Stack, depth+1)})
+		}
+		return obj
+	case logf.Frame:
+		return object{{name: "function", value: v.Function}, {name: "file", value: v.File}, {name: "line", value: int64(v.Line)}}
+	case error:
+		if nilPointer(v) {
+			return nil
+		}
+		return v.Error()
+	case logf.PropGroup:
+		return normalizeProps(v, depth)
+	case []logf.Prop:
+		return normalizeProps(v, depth)
+	case json.Marshaler:
+		if nilPointer(v) {
+			return nil
+		}
+		raw, err := v.MarshalJSON()
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return rawJSON(raw)
+	case fmt.Stringer:
+		if nilPointer(v) {
+			return nil
+		}
+		return v.String()
+	}
+	return normalizeReflect(reflect.ValueOf(value), depth)
+}
+
+// nilPointer reports whether value is a nil pointer, whose methods, like Error or String, may
+// panic.
+func nilPointer(value any) bool {
+	rv := reflect.ValueOf(value)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
+
+// normalizeProps makes an object from props, keeping one field for each name.
+func normalizeProps(props []logf.Prop, depth int) object {
+	obj := make(object, 0, len(props))
+	for _, prop := range props {
+		value := normalizeAt(prop.Value, depth+1)
+		if i := slices.IndexFunc(obj, func(f field) bool { return f.name == prop.Name }); i >= 0 {
+			obj[i].value = value
+			continue
+		}
+		obj = append(obj, field{name: prop.Name, value: value})
+	}
+	return obj
+}
+
+func normalizeReflect(rv reflect.Value, depth int) any {
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint()
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return normalizeAt(rv.Elem().Interface(), depth+1)
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			raw := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(raw), rv)
+			return raw
+		}
+		list := make([]any, rv.Len())
+		for i := range list {
+			list[i] = normalizeAt(rv.Index(i).Interface(), depth+1)
+		}
+		return list
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil
+		}
+		obj := make(object, 0, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			obj = append(obj, field{name: fmt.Sprint(iter.Key().Interface()), value: normalizeAt(iter.Value().Interface(), depth+1)})
+		}
+		sort.Slice(obj, func(i, j int) bool { return obj[i].name < obj[j].name })
+		return obj
+	case reflect.Struct:
+		if raw, err := json.Marshal(rv.Interface()); err == nil {
+			return rawJSON(raw)
+		}
+	}
+	return fmt.Sprint(rv.Interface())
+}
+
+// textValue formats a prop value for text formats, consistently with normalize:
+//   - Strings are written as is, and numbers and bools like fmt.Sprint
+//   - Errors are their message, frames are file:line, and stacks are formatted with StackTrace
+//   - []byte is base64, and times and durations are formatted as for JSON
+//   - Lists, objects, and structs are compact JSON
+func textValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case error:
+		if nilPointer(v) {
+			return fmt.Sprint(nil)
+		}
+		return v.Error()
+	case logf.Frame:
+		return v.String()
+	case []logf.Frame:
+		return StackTrace(v)
+	case nil:
+		return fmt.Sprint(v)
+	}
+	switch v := normalize(value).(type) {
+	case nil:
+		return fmt.Sprint(value)
+	case string:
+		return v
+	case bool, int64, uint64, float64:
+		return fmt.Sprint(v)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case rawJSON:
+		return string(v)
+	default:
+		return string(appendJSON(nil, v))
+	}
+}
+
+// appendJSON appends the JSON encoding of a normalized value:
+//   - []byte is a base64 string, as in encoding/json
+//   - NaN and infinities, which JSON can't represent, are the strings NaN, +Inf, and -Inf
+func appendJSON(buf []byte, value any) []byte {
+	switch v := value.(type) {
+	case nil:
+		return append(buf, "null"...)
+	case string:
+		return appendJSONString(buf, v)
+	case bool:
+		return strconv.AppendBool(buf, v)
+	case int64:
+		return strconv.AppendInt(buf, v, 10)
+	case uint64:
+		return strconv.AppendUint(buf, v, 10)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return appendJSONString(buf, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		format := byte('f')
+		if abs := math.Abs(v); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+			format = 'e'
+		}
+		return strconv.AppendFloat(buf, v, format, -1, 64)
+	case []byte:
+		buf = append(buf, '"')
+		buf = append(buf, base64.StdEncoding.EncodeToString(v)...)
+		return append(buf, '"')
+	case []any:
+		buf = append(buf, '[')
+		for i, item := range v {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSON(buf, item)
+		}
+		return append(buf, ']')
+	case object:
+		buf = append(buf, '{')
+		for i, f := range v {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONString(buf, f.name)
+			buf = append(buf, ':')
+			buf = appendJSON(buf, f.value)
+		}
+		return append(buf, '}')
+	case rawJSON:
+		if json.Valid(v) {
+			return append(buf, v...)
+		}
+		return appendJSONString(buf, string(v))
+	}
+	return appendJSONString(buf, fmt.Sprint(value))
+}
+
+const jsonHex = "0123456789abcdef"
+
+// appendJSONString appends s as a JSON string. Invalid UTF-8 is replaced with U+FFFD.
+func appendJSONString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				buf = append(buf, '\\', c)
+			case c == '\n':
+				buf = append(buf, '\\', 'n')
+			case c == '\r':
+				buf = append(buf, '\\', 'r')
+			case c == '\t':
+				buf = append(buf, '\\', 't')
+			case c < 0x20:
+				buf = append(buf, '\\', 'u', '0', '0', jsonHex[c>>4], jsonHex[c&0xf])
+			default:
+				buf = append(buf, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			buf = append(buf, `\ufffd`...)
+		case r == '\u2028' || r == '\u2029':
+			buf = append(buf, `\u202`...)
+			buf = append(buf, jsonHex[r&0xf])
+		default:
+			buf = append(buf, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(buf, '"')
+}
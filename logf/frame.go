@@ -0,0 +1,145 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logf
+
+import (
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SOURCE is the prop that holds the source location of a log call, as a Frame.
+// Formats with a source location field, like GCP's sourceLocation, read it from this prop.
+const SOURCE = string("source")
+
+// STACK is the prop that holds a stack trace, as a []Frame with the innermost call first.
+// Config.AddStack sets it.
+const STACK = string("stack")
+
+// Frame is a location in source code.
+// Function is the package path-qualified function name, like runtime.Frame.Function.
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// String returns the frame as file:line.
+func (frame Frame) String() string {
+	return frame.File + ":" + strconv.Itoa(frame.Line)
+}
+
+// maxStackDepth is the most frames recorded for an error's stack.
+const maxStackDepth = 64
+
+// defaultStackDepth is the default for StackOptions.MaxDepth.
+const defaultStackDepth = 32
+
+// callers returns the stack of the calling function's caller, skipping skip more frames.
+func callers(skip int) []Frame {
+	pcs := make([]uintptr, maxStackDepth)
+	return framesOf(pcs[:runtime.Callers(skip+3, pcs)])
+}
+
+// framesOf resolves program counters from runtime.Callers into frames.
+func framesOf(pcs []uintptr) []Frame {
+	if len(pcs) == 0 {
+		return nil
+	}
+	frames := make([]Frame, 0, len(pcs))
+	iter := runtime.CallersFrames(pcs)
+	for {
+		frame, more := iter.Next()
+		frames = append(frames, Frame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		if !more {
+			return frames
+		}
+	}
+}
+
+// loggerPackages are skipped when looking for the caller of Logger.Log, so loggers that wrap
+// other loggers, like MultiLogger and logconfig.Tree, aren't reported as the call site.
+var loggerPackages = func() []string {
+	pkg := reflect.TypeOf(logger{}).PkgPath()
+	return []string{pkg, pkg + "/logconfig"}
+}()
+
+// caller returns the first frame outside of loggerPackages, skipping skip more frames after it.
+// It looks at a few frames first, since the caller is usually close, and the full stack only if
+// it has to.
+func caller(skip int) (Frame, bool) {
+	var buf [8]uintptr
+	for depth := len(buf); depth <= maxStackDepth+skip; depth *= 4 {
+		pcs := buf[:]
+		if depth > len(buf) {
+			pcs = make([]uintptr, depth)
+		}
+		n := runtime.Callers(3, pcs)
+		remaining := skip
+		iter := runtime.CallersFrames(pcs[:n])
+		for {
+			frame, more := iter.Next()
+			if frame.PC != 0 && !slices.Contains(loggerPackages, funcPackage(frame.Function)) {
+				if remaining == 0 {
+					return Frame{Function: frame.Function, File: frame.File, Line: frame.Line}, true
+				}
+				remaining--
+			}
+			if !more {
+				break
+			}
+		}
+		if n < len(pcs) {
+			// That was the whole stack.
+			return Frame{}, false
+		}
+	}
+	return Frame{}, false
+}
+
+// funcPackage returns the package path of a function name from runtime.Frame.Function, like
+// example.com/pkg for example.com/pkg.(*T).Method.
+func funcPackage(function string) string {
+	slash := strings.LastIndexByte(function, '/') + 1
+	if dot := strings.IndexByte(function[slash:], '.'); dot >= 0 {
+		return function[:slash+dot]
+	}
+	return function
+}
+
+// stack returns the stack of Logger.Log's caller, trimmed and capped as opts says.
+func stack(opts StackOptions) []Frame {
+	depth := opts.MaxDepth
+	if depth <= 0 {
+		depth = defaultStackDepth
+	}
+	// Trimmed frames don't count toward the depth, so look a little deeper.
+	pcs := make([]uintptr, depth+16)
+	frames := framesOf(pcs[:runtime.Callers(3, pcs)])
+	kept := frames[:0]
+	for _, frame := range frames {
+		pkg := funcPackage(frame.Function)
+		if !opts.KeepLogf && slices.Contains(loggerPackages, pkg) {
+			continue
+		}
+		if !opts.KeepRuntime && pkg == "runtime" {
+			continue
+		}
+		kept = append(kept, frame)
+	}
+	return kept[:min(len(kept), depth)]
+}
@@ -14,15 +14,152 @@
 
 package logf
 
-var active Logger
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
 
-func Use(log Logger) {
-	active = log
+// activeLogger boxes the active Logger, since atomic.Pointer needs a concrete type.
+type activeLogger struct {
+	log Logger
 }
 
-func Log(level LogLevel, message string, props ...Prop) error {
-	if active == nil {
-		return NoActiveLoggerError
+var active atomic.Pointer[activeLogger]
+
+// defaultLogger is used until Use is called: logfmt to stderr, logging Informational and more
+// severe messages. It's created on first use.
+var defaultLogger = sync.OnceValue(func() Logger {
+	log, _ := NewLogger(Config{
+		MaxLevel:     Informational,
+		DefaultLevel: Informational,
+		Format:       defaultFormat,
+		Output:       os.Stderr,
+	})
+	return log
+})
+
+// defaultFormat writes logfmt like formats.KVFormat with Logfmt set. It's built in so the default
+// logger doesn't depend on package formats.
+func defaultFormat(level LogLevel, msg string, props PropsView) string {
+	var out strings.Builder
+	out.WriteString("level=")
+	out.WriteString(defaultValue(props.LevelName(level)))
+	out.WriteString(" timestamp=")
+	out.WriteString(time.Now().UTC().Format(time.RFC3339))
+	out.WriteString(" message=")
+	out.WriteString(defaultValue(msg))
+	writeDefaultProps(&out, "", props.Slice())
+	return out.String()
+}
+
+// writeDefaultProps writes props for defaultFormat. Like formats.KVFormat, props in a Group are
+// written with dotted keys, like http.method.
+func writeDefaultProps(out *strings.Builder, prefix string, props []Prop) {
+	for _, prop := range props {
+		if group, ok := prop.Value.(PropGroup); ok {
+			writeDefaultProps(out, prefix+prop.Name+".", group)
+			continue
+		}
+		out.WriteByte(' ')
+		out.WriteString(prefix + prop.Name)
+		out.WriteByte('=')
+		str, ok := prop.Value.(string)
+		if !ok {
+			str = fmt.Sprint(prop.Value)
+		}
+		out.WriteString(defaultValue(str))
+	}
+}
+
+func defaultValue(str string) string {
+	unsafe := func(r rune) bool {
+		return r == '=' || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r)
 	}
-	return active.Log(level, message, props...)
+	if str == "" || strings.IndexFunc(str, unsafe) >= 0 {
+		return strconv.Quote(str)
+	}
+	return str
+}
+
+type useHook struct {
+	id   int
+	hook func(Logger)
+}
+
+var hooksMu sync.Mutex
+var hooks []useHook
+var nextHookID int
+
+// useMu is held across the swap and the hooks in Use, so hooks see loggers in the order they
+// were installed.
+var useMu sync.Mutex
+
+// Use sets the logger used by the package-level functions, and returns the previous one, so
+// tests can put it back:
+//
+//	defer logf.Use(logf.Use(testLogger))
+//
+// It's safe to call while other goroutines are logging; each message goes to either the old or
+// the new logger. Use(nil) restores the default logger. Hooks added with OnUse are called after
+// the swap, before another Use can swap again, so they must not call Use themselves.
+func Use(log Logger) Logger {
+	useMu.Lock()
+	defer useMu.Unlock()
+	var previous *activeLogger
+	if log == nil {
+		previous = active.Swap(nil)
+		log = defaultLogger()
+	} else {
+		previous = active.Swap(&activeLogger{log: log})
+	}
+
+	hooksMu.Lock()
+	called := append([]useHook{}, hooks...)
+	hooksMu.Unlock()
+	for _, h := range called {
+		h.hook(log)
+	}
+
+	if previous == nil {
+		return defaultLogger()
+	}
+	return previous.log
+}
+
+// Active returns the logger used by the package-level functions.
+// Until Use is called, that's a default logger that writes logfmt to stderr, at Informational.
+func Active() Logger {
+	if current := active.Load(); current != nil {
+		return current.log
+	}
+	return defaultLogger()
+}
+
+// OnUse adds a hook that's called with the new logger whenever Use is called, for libraries that
+// keep their own reference to the active logger. Hooks run in the order they were added, on the
+// goroutine that called Use. Call remove to remove the hook.
+func OnUse(hook func(Logger)) (remove func()) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	nextHookID++
+	id := nextHookID
+	hooks = append(hooks, useHook{id: id, hook: hook})
+	return func() {
+		hooksMu.Lock()
+		defer hooksMu.Unlock()
+		hooks = slices.DeleteFunc(hooks, func(h useHook) bool {
+			return h.id == id
+		})
+	}
+}
+
+func Log(level LogLevel, message string, props ...Prop) error {
+	return Active().Log(level, message, props...)
 }
@@ -0,0 +1,105 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logf_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+func TestGlobal(t *testing.T) {
+	defaultLog := logf.Active()
+	if defaultLog == nil {
+		t.Fatal("no default logger")
+	}
+	if err := logf.Log(logf.Debug, "below the default max level"); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	testLog, _ := logf.NewLogger(logf.Config{
+		MaxLevel: logf.Debug,
+		Format: func(level logf.LogLevel, msg string, props logf.PropsView) string {
+			return props.LevelName(level) + " " + msg
+		},
+		Output: &out,
+	})
+	var seen []logf.Logger
+	remove := logf.OnUse(func(log logf.Logger) {
+		seen = append(seen, log)
+	})
+
+	previous := logf.Use(testLog)
+	if previous != defaultLog {
+		t.Error("Use didn't return the default logger")
+	}
+	logf.Log(logf.Debug, "to the test logger")
+	if out.String() != "debug to the test logger\n" {
+		t.Errorf("wrong output: %q", out.String())
+	}
+	if restored := logf.Use(previous); restored != testLog || logf.Active() != defaultLog {
+		t.Error("Use didn't swap back to the default logger")
+	}
+	remove()
+	logf.Use(testLog)
+	if logf.Use(nil) != testLog || logf.Active() != defaultLog {
+		t.Error("Use(nil) didn't restore the default logger")
+	}
+	if len(seen) != 2 || seen[0] != testLog || seen[1] != defaultLog {
+		t.Errorf("hook saw %d swaps, wanted 2 before it was removed", len(seen))
+	}
+}
+
+func TestUseHookOrder(t *testing.T) {
+	defer logf.Use(logf.Use(nil))
+	loggers := make([]logf.Logger, 8)
+	for i := range loggers {
+		loggers[i] = logf.NewMultiLogger()
+	}
+	var last logf.Logger
+	remove := logf.OnUse(func(log logf.Logger) {
+		last = log
+	})
+	defer remove()
+	for round := 0; round < 100; round++ {
+		var wg sync.WaitGroup
+		for _, log := range loggers {
+			wg.Add(1)
+			go func(log logf.Logger) {
+				defer wg.Done()
+				logf.Use(log)
+			}(log)
+		}
+		wg.Wait()
+		if last != logf.Active() {
+			t.Fatalf("round %d: the last hook call wasn't for the active logger", round)
+		}
+	}
+}
+
+func TestDefaultFormatGroups(t *testing.T) {
+	props := logf.NewProps(
+		logf.Group("http", logf.String("method", "GET"), logf.Group("client", logf.String("ip", "10.0.0.1"))),
+		logf.Int("status", 200),
+	)
+	defer props.Return()
+	out := logf.DefaultFormat.FormatAndNormalize(logf.Informational, "request done", props)
+	if !strings.HasSuffix(out, ` message="request done" http.method=GET http.client.ip=10.0.0.1 status=200`+"\n") {
+		t.Errorf("groups weren't written with dotted keys: %q", out)
+	}
+}
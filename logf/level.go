@@ -14,6 +14,14 @@
 
 package logf
 
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
 // LogLevels denote log severity, with lower values being more severe
 // The native set of LogLevels follows syslog severity, from EMERGENCY/0 to DEBUG/7.
 // You can define extra constants using const LEVEL = LogLevel(<value>) but most logging
@@ -32,7 +40,15 @@ package logf
 //	log.Error.SetKeyword("ERROR")
 //	fmt.Println(log.Error) // ERROR
 //
-// Some presets are provided through log.Keywords_X() functions.
+// Some presets are provided through log.Keywords_X() functions. SetKeyword and the presets change
+// the default LevelNames for the whole program; to name levels for one logger or format, set
+// Config.LevelNames or the format config's LevelNames instead.
+//
+// ParseLevel reverses String. LogLevel implements encoding.TextMarshaler, json.Marshaler, and
+// their Unmarshalers, so it can be used directly in config structs, and *LogLevel is a flag.Value:
+//
+//	level := log.Informational
+//	flag.Var(&level, "level", "max log level")
 type LogLevel int8
 
 const (
@@ -49,49 +65,123 @@ const (
 const MOST_SEVERE = Emergency
 const LEAST_SEVERE = Debug
 
-var keywords map[LogLevel]string = map[LogLevel]string{
-	Emergency:     "emerg",
-	Alert:         "alert",
-	Critical:      "crit",
-	Error:         "err",
-	Warning:       "warn",
-	Notice:        "notice",
-	Informational: "info",
-	Debug:         "debug",
+// keywords is the default LevelNames. It's replaced, never changed, so it's safe to read while
+// another goroutine calls SetKeyword.
+var keywords atomic.Pointer[LevelNames]
+
+func init() {
+	keywords.Store(SyslogLevelNames)
 }
 
+// SetKeyword sets the level's name in the default LevelNames.
 func (level LogLevel) SetKeyword(keyword string) {
-	keywords[level] = keyword
+	for {
+		current := keywords.Load()
+		if keywords.CompareAndSwap(current, current.With(level, keyword)) {
+			return
+		}
+	}
 }
 
+// String returns the level's name in the default LevelNames.
 func (level LogLevel) String() string {
-	return keywords[level]
+	return keywords.Load().Name(level)
+}
+
+// levelAliases are the names ParseLevel accepts besides the names in LevelNames tables: the full
+// syslog names, and panic, syslog's deprecated name for emerg.
+var levelAliases = map[string]LogLevel{
+	"emergency": Emergency,
+	"panic":     Emergency,
+	"warning":   Warning,
+}
+
+// ParseLevel returns the level named by str.
+// Usage notes:
+//   - The default LevelNames are checked first, so levels named with SetKeyword can be parsed
+//   - The names from every LevelNames preset and common aliases like warning are accepted
+//   - Matching is case-insensitive, and ignores surrounding spaces
+//   - Numbers are accepted as the level's value, like 4 for Warning
+func ParseLevel(str string) (LogLevel, error) {
+	str = strings.TrimSpace(str)
+	for _, names := range append([]*LevelNames{keywords.Load()}, levelNamePresetOrder...) {
+		if level, ok := names.Parse(str); ok {
+			return level, nil
+		}
+	}
+	if level, ok := levelAliases[strings.ToLower(str)]; ok {
+		return level, nil
+	}
+	if n, err := strconv.ParseInt(str, 10, 8); err == nil {
+		return LogLevel(n), nil
+	}
+	return 0, fmt.Errorf("%w: %q", UnknownLevelError, str)
+}
+
+// MarshalText returns the level's keyword, or its number if it doesn't have one.
+func (level LogLevel) MarshalText() ([]byte, error) {
+	if keyword := level.String(); keyword != "" {
+		return []byte(keyword), nil
+	}
+	return strconv.AppendInt(nil, int64(level), 10), nil
+}
+
+// UnmarshalText sets level with ParseLevel.
+func (level *LogLevel) UnmarshalText(text []byte) error {
+	parsed, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*level = parsed
+	return nil
+}
+
+// MarshalJSON writes the level as a JSON string, like MarshalText.
+func (level LogLevel) MarshalJSON() ([]byte, error) {
+	text, _ := level.MarshalText()
+	return json.Marshal(string(text))
+}
+
+// UnmarshalJSON accepts a string for ParseLevel, or a number.
+func (level *LogLevel) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		return level.UnmarshalText([]byte(str))
+	}
+	var n int8
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("%w: %s", UnknownLevelError, data)
+	}
+	*level = LogLevel(n)
+	return nil
+}
+
+// Set implements flag.Value with ParseLevel.
+func (level *LogLevel) Set(str string) error {
+	return level.UnmarshalText([]byte(str))
 }
 
 // ===== KEYWORD SETS =====
 
+// Keywords_Syslog makes SyslogLevelNames the default LevelNames.
 func Keywords_Syslog() {
-	keywords = map[LogLevel]string{
-		Emergency:     "emerg",
-		Alert:         "alert",
-		Critical:      "crit",
-		Error:         "err",
-		Warning:       "warn",
-		Notice:        "notice",
-		Informational: "info",
-		Debug:         "debug",
-	}
+	keywords.Store(SyslogLevelNames)
 }
 
+// Keywords_AllCaps makes AllCapsLevelNames the default LevelNames.
 func Keywords_AllCaps() {
-	keywords = map[LogLevel]string{
-		Emergency:     "EMERGENCY",
-		Alert:         "ALERT",
-		Critical:      "CRITICAL",
-		Error:         "ERROR",
-		Warning:       "WARN",
-		Notice:        "NOTICE",
-		Informational: "INFORMATIONAL",
-		Debug:         "DEBUG",
+	keywords.Store(AllCapsLevelNames)
+}
+
+// UseLevelNames makes names the default LevelNames. nil restores SyslogLevelNames.
+func UseLevelNames(names *LevelNames) {
+	if names == nil {
+		names = SyslogLevelNames
 	}
+	keywords.Store(names)
+}
+
+// DefaultLevelNames returns the default LevelNames, used by LogLevel.String.
+func DefaultLevelNames() *LevelNames {
+	return keywords.Load()
 }
@@ -0,0 +1,180 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logf_test
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/decentplatforms/appkit/logf"
+	"github.com/decentplatforms/appkit/logf/testhelp"
+)
+
+func TestParseLevel(t *testing.T) {
+	t.Cleanup(logf.Keywords_Syslog)
+	logf.Keywords_AllCaps()
+	logf.LogLevel(9).SetKeyword("trace")
+
+	inputs := map[string]string{
+		"syslog":     "warn",
+		"allcaps":    "WARN",
+		"mixed_case": "Warning",
+		"alias":      "error",
+		"long":       "informational",
+		"panic":      "panic",
+		"spaces":     " crit ",
+		"number":     "4",
+		"custom":     "TRACE",
+	}
+	wanted := testhelp.ResultsMap{
+		"syslog":     "4",
+		"allcaps":    "4",
+		"mixed_case": "4",
+		"alias":      "3",
+		"long":       "6",
+		"panic":      "0",
+		"spaces":     "2",
+		"number":     "4",
+		"custom":     "9",
+	}
+	res := testhelp.ResultsMap{}
+	for name, input := range inputs {
+		level, err := logf.ParseLevel(input)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		res[name] = fmt.Sprint(int(level))
+	}
+	if err := testhelp.ValidateResults(res, wanted); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range []string{"", "loud", "200"} {
+		if _, err := logf.ParseLevel(input); !errors.Is(err, logf.UnknownLevelError) {
+			t.Errorf("%q: got %v, want %v", input, err, logf.UnknownLevelError)
+		}
+	}
+}
+
+func TestLevelMarshal(t *testing.T) {
+	type config struct {
+		Level   logf.LogLevel
+		Default logf.LogLevel
+		Custom  logf.LogLevel
+	}
+	var conf config
+	if err := json.Unmarshal([]byte(`{"Level":"warning","Default":6,"Custom":"12"}`), &conf); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wanted := `{"Level":"warn","Default":"info","Custom":"12"}`; string(raw) != wanted {
+		t.Errorf("got %s, want %s", raw, wanted)
+	}
+	if err := json.Unmarshal([]byte(`{"Level":true}`), &conf); !errors.Is(err, logf.UnknownLevelError) {
+		t.Errorf("got %v, want %v", err, logf.UnknownLevelError)
+	}
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	level := logf.Informational
+	flags.Var(&level, "level", "max log level")
+	if err := flags.Parse([]string{"-level", "DEBUG"}); err != nil {
+		t.Fatal(err)
+	}
+	if level != logf.Debug {
+		t.Errorf("flag set level to %v, want %v", level, logf.Debug)
+	}
+}
+
+func TestLevelNames(t *testing.T) {
+	presets := map[string]*logf.LevelNames{
+		"syslog":  logf.SyslogLevelNames,
+		"allcaps": logf.AllCapsLevelNames,
+		"python":  logf.PythonLevelNames,
+		"log4j":   logf.Log4jLevelNames,
+		"otel":    logf.OTelLevelNames,
+		"letters": logf.LetterLevelNames,
+	}
+	wanted := testhelp.ResultsMap{
+		"syslog":  "alert warn alert",
+		"allcaps": "ALERT WARN alert",
+		"python":  "CRITICAL WARNING crit",
+		"log4j":   "FATAL WARN crit",
+		"otel":    "FATAL3 WARN alert",
+		"letters": "A W alert",
+	}
+	res := testhelp.ResultsMap{}
+	for name, names := range presets {
+		// Shared names parse to the least severe level that has them.
+		parsed, ok := names.Parse(strings.ToLower(names.Name(logf.Alert)))
+		if !ok {
+			t.Fatalf("%s: can't parse its own name for alert", name)
+		}
+		res[name] = fmt.Sprintf("%s %s %s", names.Name(logf.Alert), names.Name(logf.Warning), parsed)
+	}
+	if err := testhelp.ValidateResults(res, wanted); err != nil {
+		t.Fatal(err)
+	}
+
+	renamed := logf.PythonLevelNames.With(logf.Notice, "NOTICE")
+	if logf.PythonLevelNames.Name(logf.Notice) != "INFO" || renamed.Name(logf.Notice) != "NOTICE" {
+		t.Error("With changed the original table")
+	}
+	var fromText logf.LevelNames
+	if err := fromText.UnmarshalText([]byte("Log4j")); err != nil || fromText.Name(logf.Error) != "ERROR" {
+		t.Errorf("preset didn't load from text: %v", err)
+	}
+	if err := fromText.UnmarshalText([]byte("nope")); err == nil {
+		t.Error("unknown preset loaded from text")
+	}
+}
+
+func TestConfigLevelNames(t *testing.T) {
+	var out strings.Builder
+	format := func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		return props.LevelName(level) + " " + msg
+	}
+	python, _ := logf.NewLogger(logf.Config{MaxLevel: logf.Debug, Format: format, Output: &out, LevelNames: logf.PythonLevelNames})
+	plain, _ := logf.NewLogger(logf.Config{MaxLevel: logf.Debug, Format: format, Output: &out})
+	python.Log(logf.Warning, "one")
+	plain.Log(logf.Warning, "two")
+	if out.String() != "WARNING one\nwarn two\n" {
+		t.Errorf("wrong level names: %q", out.String())
+	}
+}
+
+func TestSetKeywordRace(t *testing.T) {
+	t.Cleanup(logf.Keywords_Syslog)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			logf.Warning.SetKeyword(fmt.Sprint("warn", i%2))
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		_ = logf.Warning.String()
+	}
+	<-done
+	if logf.Warning.String() != "warn1" {
+		t.Errorf("last SetKeyword was lost: %q", logf.Warning.String())
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logf
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// LevelNames is an immutable table of level names.
+// Usage notes:
+//   - Build one with NewLevelNames or With, or use a preset like PythonLevelNames
+//   - Several levels may share a name, like Python's CRITICAL for Emergency, Alert, and Critical.
+//     Parse returns the least severe of them.
+//   - A nil *LevelNames uses the default LevelNames
+//   - *LevelNames implements encoding.TextUnmarshaler with preset names, so format configs can
+//     choose a preset in a config file: syslog, allcaps, python, log4j, otel, or letters
+type LevelNames struct {
+	names map[LogLevel]string
+	// order lists the named levels from least to most severe, for Parse.
+	order []LogLevel
+}
+
+// NewLevelNames returns a LevelNames with a copy of names.
+func NewLevelNames(names map[LogLevel]string) *LevelNames {
+	table := &LevelNames{names: make(map[LogLevel]string, len(names))}
+	for level, name := range names {
+		table.names[level] = name
+		table.order = append(table.order, level)
+	}
+	slices.Sort(table.order)
+	slices.Reverse(table.order)
+	return table
+}
+
+// Name returns the name of level, or "" if it doesn't have one.
+func (names *LevelNames) Name(level LogLevel) string {
+	if names == nil {
+		names = DefaultLevelNames()
+	}
+	return names.names[level]
+}
+
+// Parse returns the level named str, ignoring case.
+func (names *LevelNames) Parse(str string) (LogLevel, bool) {
+	if names == nil {
+		names = DefaultLevelNames()
+	}
+	for _, level := range names.order {
+		if name := names.names[level]; name != "" && strings.EqualFold(name, str) {
+			return level, true
+		}
+	}
+	return 0, false
+}
+
+// With returns a copy of names with level named name.
+func (names *LevelNames) With(level LogLevel, name string) *LevelNames {
+	if names == nil {
+		names = DefaultLevelNames()
+	}
+	copied := make(map[LogLevel]string, len(names.names)+1)
+	for l, n := range names.names {
+		copied[l] = n
+	}
+	copied[level] = name
+	return NewLevelNames(copied)
+}
+
+// MaxWidth returns the length of the longest name for the syslog levels, for aligning output.
+func (names *LevelNames) MaxWidth() int {
+	width := 0
+	for level := MOST_SEVERE; level <= LEAST_SEVERE; level++ {
+		width = max(width, len(names.Name(level)))
+	}
+	return width
+}
+
+// UnmarshalText sets names to the preset named by text.
+func (names *LevelNames) UnmarshalText(text []byte) error {
+	preset, ok := levelNamePresets[strings.ToLower(string(text))]
+	if !ok {
+		return fmt.Errorf("unknown level names preset %s", strconv.Quote(string(text)))
+	}
+	*names = *preset
+	return nil
+}
+
+// ===== PRESETS =====
+
+// SyslogLevelNames are the syslog keywords, like err and warn. They're the default.
+var SyslogLevelNames = NewLevelNames(map[LogLevel]string{
+	Emergency:     "emerg",
+	Alert:         "alert",
+	Critical:      "crit",
+	Error:         "err",
+	Warning:       "warn",
+	Notice:        "notice",
+	Informational: "info",
+	Debug:         "debug",
+})
+
+// AllCapsLevelNames are full upper-case names, like ERROR and WARN.
+var AllCapsLevelNames = NewLevelNames(map[LogLevel]string{
+	Emergency:     "EMERGENCY",
+	Alert:         "ALERT",
+	Critical:      "CRITICAL",
+	Error:         "ERROR",
+	Warning:       "WARN",
+	Notice:        "NOTICE",
+	Informational: "INFORMATIONAL",
+	Debug:         "DEBUG",
+})
+
+// PythonLevelNames are the Python logging level names.
+var PythonLevelNames = NewLevelNames(map[LogLevel]string{
+	Emergency:     "CRITICAL",
+	Alert:         "CRITICAL",
+	Critical:      "CRITICAL",
+	Error:         "ERROR",
+	Warning:       "WARNING",
+	Notice:        "INFO",
+	Informational: "INFO",
+	Debug:         "DEBUG",
+})
+
+// Log4jLevelNames are the log4j and java.util.logging style names used on the JVM.
+var Log4jLevelNames = NewLevelNames(map[LogLevel]string{
+	Emergency:     "FATAL",
+	Alert:         "FATAL",
+	Critical:      "FATAL",
+	Error:         "ERROR",
+	Warning:       "WARN",
+	Notice:        "INFO",
+	Informational: "INFO",
+	Debug:         "DEBUG",
+})
+
+// OTelLevelNames are the OpenTelemetry short severity names, as written by formats.OTelFormat.
+var OTelLevelNames = NewLevelNames(map[LogLevel]string{
+	Emergency:     "FATAL4",
+	Alert:         "FATAL3",
+	Critical:      "FATAL",
+	Error:         "ERROR",
+	Warning:       "WARN",
+	Notice:        "INFO2",
+	Informational: "INFO",
+	Debug:         "DEBUG",
+})
+
+// LetterLevelNames are single letters, like E, W, I, and D. Emergency is F, for fatal.
+var LetterLevelNames = NewLevelNames(map[LogLevel]string{
+	Emergency:     "F",
+	Alert:         "A",
+	Critical:      "C",
+	Error:         "E",
+	Warning:       "W",
+	Notice:        "N",
+	Informational: "I",
+	Debug:         "D",
+})
+
+var levelNamePresets = map[string]*LevelNames{
+	"syslog":  SyslogLevelNames,
+	"allcaps": AllCapsLevelNames,
+	"python":  PythonLevelNames,
+	"log4j":   Log4jLevelNames,
+	"otel":    OTelLevelNames,
+	"letters": LetterLevelNames,
+}
+
+// levelNamePresetOrder is the order ParseLevel tries the presets in.
+var levelNamePresetOrder = []*LevelNames{
+	SyslogLevelNames,
+	AllCapsLevelNames,
+	PythonLevelNames,
+	Log4jLevelNames,
+	OTelLevelNames,
+	LetterLevelNames,
+}
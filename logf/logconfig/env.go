@@ -0,0 +1,126 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logconfig
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const EnvPrefix = "LOGF_"
+
+// FromEnv reads a Spec from LOGF_* environment variables:
+//   - LOGF_LEVEL and LOGF_DEFAULT_LEVEL set the levels
+//   - LOGF_FORMAT sets the format name, and LOGF_FORMAT_<OPTION> sets its options, like
+//     LOGF_FORMAT_TIME_FORMAT for time_format
+//   - LOGF_OUTPUT sets the output type, and LOGF_OUTPUT_<FIELD> sets its fields, like LOGF_OUTPUT_PATH
+//   - LOGF_SINKS_<N>_<VAR> sets the same variables for sink N, like LOGF_SINKS_0_FORMAT. Sinks are
+//     numbered from 0, and a gap, like LOGF_SINKS_1_* without LOGF_SINKS_0_*, is an error.
+//
+// Option values are typed like YAML plain scalars, so LOGF_FORMAT_FLATTEN=true is a bool.
+// Quote values to keep them strings, like LOGF_FORMAT_APP_NAME='"123"'.
+func FromEnv() (*Spec, error) {
+	return fromEnv(os.Environ())
+}
+
+func fromEnv(environ []string) (*Spec, error) {
+	vars := map[string]string{}
+	names := []string{}
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, EnvPrefix) {
+			vars[name] = value
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	root := map[string]any{}
+	sinks := map[int]map[string]any{}
+	for _, name := range names {
+		key := strings.TrimPrefix(name, EnvPrefix)
+		target := root
+		if rest, ok := strings.CutPrefix(key, "SINKS_"); ok {
+			idx, sinkKey, _ := strings.Cut(rest, "_")
+			i, err := strconv.Atoi(idx)
+			if err != nil || i < 0 || i > 1024 {
+				return nil, &PathError{Path: name, Err: UnknownKeyError}
+			}
+			if sinks[i] == nil {
+				sinks[i] = map[string]any{}
+			}
+			target, key = sinks[i], sinkKey
+		}
+		if !setEnvKey(target, key, vars[name]) {
+			return nil, &PathError{Path: name, Err: UnknownKeyError}
+		}
+	}
+	if len(sinks) > 0 {
+		// A missing index would be a sink with every default, so it's more likely a typo.
+		list := make([]any, len(sinks))
+		for i := range list {
+			if sinks[i] == nil {
+				return nil, &PathError{Path: EnvPrefix + "SINKS_" + strconv.Itoa(i), Err: fmt.Errorf("%w: sinks must be numbered from 0 without gaps", MissingValueError)}
+			}
+			list[i] = sinks[i]
+		}
+		root["sinks"] = list
+	}
+	return specFromDoc(root)
+}
+
+// setEnvKey sets the sink key for an environment variable, without its prefix.
+func setEnvKey(sink map[string]any, key, value string) bool {
+	sub := func(name string) map[string]any {
+		if m, ok := sink[name].(map[string]any); ok {
+			return m
+		}
+		m := map[string]any{}
+		sink[name] = m
+		return m
+	}
+	switch {
+	case key == "LEVEL":
+		sink["level"] = value
+	case key == "DEFAULT_LEVEL":
+		sink["default_level"] = value
+	case key == "FORMAT":
+		sub("format")["name"] = value
+	case strings.HasPrefix(key, "FORMAT_"):
+		sub("format")[strings.ToLower(key[len("FORMAT_"):])] = envScalar(value)
+	case key == "OUTPUT":
+		sub("output")["type"] = value
+	case key == "OUTPUT_BUFFER":
+		sub("output")["buffer"] = envScalar(value)
+	case strings.HasPrefix(key, "OUTPUT_"):
+		sub("output")[strings.ToLower(key[len("OUTPUT_"):])] = value
+	default:
+		return false
+	}
+	return true
+}
+
+// envScalar types a value like a YAML plain scalar, or unquotes it if it's quoted.
+func envScalar(value string) any {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		if str, err := unquote(value); err == nil {
+			return str
+		}
+	}
+	return resolveScalar(value)
+}
@@ -0,0 +1,519 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/decentplatforms/appkit/logf"
+	"github.com/decentplatforms/appkit/logf/formats"
+	"github.com/decentplatforms/appkit/logf/output"
+)
+
+var WrongTypeError = errors.New("wrong type")
+var UnknownKeyError = errors.New("unknown key")
+var MissingValueError = errors.New("missing required value")
+var BadValueError = errors.New("bad value")
+var UnknownLevelError = logf.UnknownLevelError
+var UnknownOutputError = errors.New("unknown output type")
+var TreeClosedError = errors.New("logger tree is closed")
+
+// PathError is an error in a config document.
+// Path locates the bad value, like sinks[2].format.indent.
+type PathError struct {
+	Path string
+	Err  error
+}
+
+func (err *PathError) Error() string {
+	return err.Path + ": " + err.Err.Error()
+}
+
+func (err *PathError) Unwrap() error {
+	return err.Err
+}
+
+// Spec describes a logger tree.
+// Usage notes:
+//   - Level is the max level logged, and DefaultLevel is the level for Logger.Write. Both default
+//     to info, and accept anything logf.ParseLevel does.
+//   - Format and Output are used by sinks that don't set their own. Without Sinks, the tree has
+//     one sink that uses them.
+//   - The format defaults to syslog5424 for syslog outputs, and json for everything else
+//   - The output defaults to stdout
+type Spec struct {
+	Level        string
+	DefaultLevel string
+	Format       FormatSpec
+	Output       OutputSpec
+	Sinks        []SinkSpec
+}
+
+// SinkSpec describes one logger in the tree. Empty fields use the values from Spec.
+type SinkSpec struct {
+	Level        string
+	DefaultLevel string
+	Format       FormatSpec
+	Output       OutputSpec
+}
+
+// FormatSpec names a format registered with formats.Register, and its options.
+type FormatSpec struct {
+	Name    string
+	Options map[string]any
+}
+
+// OutputSpec describes where a sink writes.
+// Usage notes:
+//   - Type is stdout, stderr, file, or syslog
+//   - Path is the file to append to, and Buffer is the size of its write queue, up to 255
+//   - Network and Address locate a syslog daemon, like udp and localhost:514. Without an Address,
+//     the local syslog socket is used. Network defaults to udp.
+type OutputSpec struct {
+	Type    string
+	Path    string
+	Buffer  int
+	Network string
+	Address string
+}
+
+// ParseFile reads a config document from path with Parse.
+func ParseFile(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse reads a config document. Documents starting with { are JSON; anything else is YAML.
+// YAML support covers what config files need; see parseYAML for the details.
+//
+//	level: info
+//	format: json
+//	sinks:
+//	  - output: stdout
+//	  - level: debug
+//	    format: {name: kv, time_format: "15:04:05"}
+//	    output: {type: file, path: /var/log/app.log}
+//
+// Format may be a name, or a mapping with a name key and the format's options. Output may be a
+// type, or a mapping with OutputSpec's fields. Keys are snake_case.
+func Parse(data []byte) (*Spec, error) {
+	var doc any
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &doc); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if doc, err = parseYAML(string(data)); err != nil {
+			return nil, err
+		}
+	}
+	return specFromDoc(doc)
+}
+
+func specFromDoc(doc any) (*Spec, error) {
+	spec := &Spec{}
+	if doc == nil {
+		return spec, nil
+	}
+	root, ok := doc.(map[string]any)
+	if !ok {
+		return nil, &PathError{Path: "(root)", Err: fmt.Errorf("%w: want a mapping", WrongTypeError)}
+	}
+	sink := SinkSpec{}
+	err := decodeMap("", root, map[string]func(string, any) error{
+		"level":         stringField(&sink.Level),
+		"default_level": stringField(&sink.DefaultLevel),
+		"format":        formatField(&sink.Format),
+		"output":        outputField(&sink.Output),
+		"sinks": func(path string, value any) error {
+			list, ok := value.([]any)
+			if !ok {
+				return &PathError{Path: path, Err: fmt.Errorf("%w: want a list", WrongTypeError)}
+			}
+			for i, item := range list {
+				sink, err := sinkFromDoc(fmt.Sprintf("%s[%d]", path, i), item)
+				if err != nil {
+					return err
+				}
+				spec.Sinks = append(spec.Sinks, sink)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	spec.Level, spec.DefaultLevel, spec.Format, spec.Output = sink.Level, sink.DefaultLevel, sink.Format, sink.Output
+	return spec, nil
+}
+
+func sinkFromDoc(path string, doc any) (SinkSpec, error) {
+	sink := SinkSpec{}
+	m, ok := doc.(map[string]any)
+	if !ok {
+		return sink, &PathError{Path: path, Err: fmt.Errorf("%w: want a mapping", WrongTypeError)}
+	}
+	return sink, decodeMap(path, m, map[string]func(string, any) error{
+		"level":         stringField(&sink.Level),
+		"default_level": stringField(&sink.DefaultLevel),
+		"format":        formatField(&sink.Format),
+		"output":        outputField(&sink.Output),
+	})
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// decodeMap calls the field func for each key in m, in sorted order so errors are stable.
+func decodeMap(path string, m map[string]any, fields map[string]func(string, any) error) error {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		field, ok := fields[key]
+		if !ok {
+			return &PathError{Path: joinPath(path, key), Err: UnknownKeyError}
+		}
+		if m[key] == nil {
+			continue
+		}
+		if err := field(joinPath(path, key), m[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stringField accepts strings, and numbers as their decimal string, for levels like 7.
+func stringField(dst *string) func(string, any) error {
+	return func(path string, value any) error {
+		switch v := value.(type) {
+		case string:
+			*dst = v
+		case int64:
+			*dst = strconv.FormatInt(v, 10)
+		case float64:
+			if v != math.Trunc(v) {
+				return &PathError{Path: path, Err: fmt.Errorf("%w: %v", BadValueError, v)}
+			}
+			*dst = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return &PathError{Path: path, Err: fmt.Errorf("%w: want a string", WrongTypeError)}
+		}
+		return nil
+	}
+}
+
+func intField(dst *int) func(string, any) error {
+	return func(path string, value any) error {
+		switch v := value.(type) {
+		case int64:
+			*dst = int(v)
+		case float64:
+			if v != math.Trunc(v) {
+				return &PathError{Path: path, Err: fmt.Errorf("%w: %v", BadValueError, v)}
+			}
+			*dst = int(v)
+		default:
+			return &PathError{Path: path, Err: fmt.Errorf("%w: want a number", WrongTypeError)}
+		}
+		return nil
+	}
+}
+
+func formatField(dst *FormatSpec) func(string, any) error {
+	return func(path string, value any) error {
+		switch v := value.(type) {
+		case string:
+			*dst = FormatSpec{Name: v}
+		case map[string]any:
+			name, ok := v["name"].(string)
+			if !ok {
+				return &PathError{Path: joinPath(path, "name"), Err: MissingValueError}
+			}
+			*dst = FormatSpec{Name: name, Options: map[string]any{}}
+			for key, opt := range v {
+				if key != "name" {
+					dst.Options[key] = opt
+				}
+			}
+		default:
+			return &PathError{Path: path, Err: fmt.Errorf("%w: want a name or mapping", WrongTypeError)}
+		}
+		return nil
+	}
+}
+
+func outputField(dst *OutputSpec) func(string, any) error {
+	return func(path string, value any) error {
+		switch v := value.(type) {
+		case string:
+			*dst = OutputSpec{Type: v}
+			return nil
+		case map[string]any:
+			*dst = OutputSpec{}
+			return decodeMap(path, v, map[string]func(string, any) error{
+				"type":    stringField(&dst.Type),
+				"path":    stringField(&dst.Path),
+				"buffer":  intField(&dst.Buffer),
+				"network": stringField(&dst.Network),
+				"address": stringField(&dst.Address),
+			})
+		}
+		return &PathError{Path: path, Err: fmt.Errorf("%w: want a type or mapping", WrongTypeError)}
+	}
+}
+
+// Tree is a logger built from a Spec. It logs to every sink.
+// Call Close to close the sinks' files and connections when you're done with it.
+type Tree struct {
+	logf.Logger
+	closers []io.Closer
+
+	// mu is held for reading while logging, so Close waits for in-flight writes.
+	mu     sync.RWMutex
+	closed bool
+}
+
+func (tree *Tree) Log(level logf.LogLevel, msg string, props ...logf.Prop) error {
+	tree.mu.RLock()
+	defer tree.mu.RUnlock()
+	if tree.closed {
+		return TreeClosedError
+	}
+	return tree.Logger.Log(level, msg, props...)
+}
+
+func (tree *Tree) Write(msg []byte) (n int, err error) {
+	tree.mu.RLock()
+	defer tree.mu.RUnlock()
+	if tree.closed {
+		return 0, TreeClosedError
+	}
+	return tree.Logger.Write(msg)
+}
+
+// Close waits for in-flight writes, then closes the outputs that the tree opened. Stdout and
+// stderr aren't closed. Writes after Close return TreeClosedError.
+func (tree *Tree) Close() error {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	tree.closed = true
+	var err error
+	for _, closer := range tree.closers {
+		err = errors.Join(err, closer.Close())
+	}
+	tree.closers = nil
+	return err
+}
+
+// Build validates spec and builds its logger tree.
+// Errors are *PathError, naming the bad value's path in the config document.
+func (spec *Spec) Build() (*Tree, error) {
+	sinks := spec.Sinks
+	implicit := len(sinks) == 0
+	if implicit {
+		sinks = []SinkSpec{{}}
+	}
+	tree := &Tree{}
+	logs := make([]logf.Logger, 0, len(sinks))
+	for i, sink := range sinks {
+		path := fmt.Sprintf("sinks[%d]", i)
+		if implicit {
+			path = ""
+		}
+		log, closer, err := spec.buildSink(path, sink)
+		if err != nil {
+			tree.Close()
+			return nil, err
+		}
+		if closer != nil {
+			tree.closers = append(tree.closers, closer)
+		}
+		logs = append(logs, log)
+	}
+	if len(logs) == 1 {
+		tree.Logger = logs[0]
+	} else {
+		tree.Logger = logf.NewMultiLogger(logs...)
+	}
+	return tree, nil
+}
+
+// inherit returns the sink's value and its path, or the spec's value and its path if the sink's
+// is empty.
+func inherit[T comparable](path, key string, sinkValue, specValue T) (T, string) {
+	var zero T
+	if sinkValue != zero {
+		return sinkValue, joinPath(path, key)
+	}
+	return specValue, key
+}
+
+func (spec *Spec) buildSink(path string, sink SinkSpec) (logf.Logger, io.Closer, error) {
+	levelStr, levelPath := inherit(path, "level", sink.Level, spec.Level)
+	maxLevel, err := levelOrDefault(levelPath, levelStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	defStr, defPath := inherit(path, "default_level", sink.DefaultLevel, spec.DefaultLevel)
+	defLevel, err := levelOrDefault(defPath, defStr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := sink.Output
+	outPath := joinPath(path, "output")
+	if out.Type == "" && out != (OutputSpec{}) {
+		return nil, nil, &PathError{Path: joinPath(outPath, "type"), Err: MissingValueError}
+	}
+	if out.Type == "" {
+		out, outPath = spec.Output, "output"
+	}
+	formatSpec := sink.Format
+	formatPath := joinPath(path, "format")
+	if formatSpec.Name == "" {
+		formatSpec, formatPath = spec.Format, "format"
+	}
+	if formatSpec.Name == "" {
+		formatSpec.Name = "json"
+		if out.Type == "syslog" {
+			formatSpec.Name = "syslog5424"
+		}
+	}
+	format, err := formats.Lookup(formatSpec.Name, formatSpec.Options)
+	var optErr *formats.OptionError
+	switch {
+	case errors.As(err, &optErr):
+		return nil, nil, &PathError{Path: joinPath(formatPath, optErr.Option), Err: optErr.Err}
+	case errors.Is(err, formats.UnknownFormatError):
+		return nil, nil, &PathError{Path: joinPath(formatPath, "name"), Err: err}
+	case err != nil:
+		return nil, nil, &PathError{Path: formatPath, Err: err}
+	}
+
+	writer, closer, err := openOutput(outPath, out)
+	if err != nil {
+		return nil, nil, err
+	}
+	if formatSpec.Name == "console" {
+		// The registry's console format detects a terminal on stdout, so it's rebuilt to check the
+		// sink's own writer. The options were already decoded once, so this can't fail.
+		conf := formats.ConsoleConfig{}
+		formats.DecodeOptions(formatSpec.Options, &conf)
+		conf.Output = writer
+		format = formats.ConsoleFormat(conf)
+	}
+	log, err := logf.NewLogger(logf.Config{
+		MaxLevel:     maxLevel,
+		DefaultLevel: defLevel,
+		Format:       format,
+		Output:       writer,
+	})
+	if err != nil {
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, nil, &PathError{Path: path, Err: err}
+	}
+	return log, closer, nil
+}
+
+func levelOrDefault(path, value string) (logf.LogLevel, error) {
+	if value == "" {
+		return logf.Informational, nil
+	}
+	level, err := logf.ParseLevel(value)
+	if err != nil {
+		return 0, &PathError{Path: path, Err: err}
+	}
+	return level, nil
+}
+
+type closerFunc func() error
+
+func (fn closerFunc) Close() error {
+	return fn()
+}
+
+// syslogSockets are the usual local syslog sockets, as tried by log/syslog.
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+func openOutput(path string, out OutputSpec) (io.Writer, io.Closer, error) {
+	switch out.Type {
+	case "", "stdout":
+		return os.Stdout, nil, nil
+	case "stderr":
+		return os.Stderr, nil, nil
+	case "file":
+		if out.Path == "" {
+			return nil, nil, &PathError{Path: joinPath(path, "path"), Err: MissingValueError}
+		}
+		if out.Buffer < 0 || out.Buffer > math.MaxUint8 {
+			return nil, nil, &PathError{Path: joinPath(path, "buffer"), Err: fmt.Errorf("%w: must be 0 to 255", BadValueError)}
+		}
+		f, err := output.Open(out.Path, uint8(out.Buffer))
+		if err != nil {
+			return nil, nil, &PathError{Path: joinPath(path, "path"), Err: err}
+		}
+		return f, closerFunc(func() error {
+			f.Close()
+			return nil
+		}), nil
+	case "syslog":
+		if out.Address == "" {
+			var err error
+			for _, socket := range syslogSockets {
+				for _, network := range []string{"unixgram", "unix"} {
+					var conn net.Conn
+					if conn, err = net.Dial(network, socket); err == nil {
+						return conn, conn, nil
+					}
+				}
+			}
+			return nil, nil, &PathError{Path: path, Err: fmt.Errorf("no local syslog socket: %w", err)}
+		}
+		network := out.Network
+		if network == "" {
+			network = "udp"
+		}
+		conn, err := net.Dial(network, out.Address)
+		if err != nil {
+			return nil, nil, &PathError{Path: joinPath(path, "address"), Err: err}
+		}
+		return conn, conn, nil
+	}
+	return nil, nil, &PathError{Path: joinPath(path, "type"), Err: fmt.Errorf("%w: %q", UnknownOutputError, out.Type)}
+}
@@ -0,0 +1,289 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logconfig
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+	"github.com/decentplatforms/appkit/logf/formats"
+	"github.com/decentplatforms/appkit/logf/testhelp"
+)
+
+func TestParse(t *testing.T) {
+	yamlDoc := `
+level: warning
+format: {name: kv, logfmt: true}
+sinks:
+  - output: stderr
+  - level: 7
+    default_level: notice
+    format:
+      name: json
+      indent: "  "
+      flatten: true
+    output:
+      type: file
+      path: /tmp/app.log
+      buffer: 16
+`
+	jsonDoc := `{
+		"level": "warning",
+		"format": {"name": "kv", "logfmt": true},
+		"sinks": [
+			{"output": "stderr"},
+			{"level": 7, "default_level": "notice", "format": {"name": "json", "indent": "  ", "flatten": true},
+			 "output": {"type": "file", "path": "/tmp/app.log", "buffer": 16}}
+		]
+	}`
+	for name, doc := range map[string]string{"yaml": yamlDoc, "json": jsonDoc} {
+		spec, err := Parse([]byte(doc))
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if spec.Level != "warning" || spec.Format.Name != "kv" || spec.Format.Options["logfmt"] != true || len(spec.Sinks) != 2 {
+			t.Errorf("%s: wrong spec: %+v", name, spec)
+			continue
+		}
+		sink := spec.Sinks[1]
+		if spec.Sinks[0].Output.Type != "stderr" || sink.Level != "7" || sink.DefaultLevel != "notice" ||
+			sink.Format.Name != "json" || sink.Format.Options["indent"] != "  " ||
+			sink.Output != (OutputSpec{Type: "file", Path: "/tmp/app.log", Buffer: 16}) {
+			t.Errorf("%s: wrong sinks: %+v", name, spec.Sinks)
+		}
+	}
+}
+
+func pathOf(err error) string {
+	var pathErr *PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Path
+	}
+	return "not a PathError: " + err.Error()
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown":      "levle: info",
+		"sink_key":     "sinks:\n  - output: stdout\n  - outptu: stdout",
+		"sink_type":    "sinks: [stdout]",
+		"format_name":  "sinks:\n  - format: {indent: 2}",
+		"output_key":   "output: {type: file, pth: x}",
+		"output_type":  "output: [file]",
+		"buffer":       "output: {type: file, buffer: big}",
+		"sinks":        "sinks: stdout",
+		"root":         "- a",
+		"level_format": "level: [1]",
+	}
+	expects := testhelp.ResultsMap{
+		"unknown":      "levle",
+		"sink_key":     "sinks[1].outptu",
+		"sink_type":    "sinks[0]",
+		"format_name":  "sinks[0].format.name",
+		"output_key":   "output.pth",
+		"output_type":  "output",
+		"buffer":       "output.buffer",
+		"sinks":        "sinks",
+		"root":         "(root)",
+		"level_format": "level",
+	}
+	res := testhelp.ResultsMap{}
+	for name, doc := range tests {
+		_, err := Parse([]byte(doc))
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+			continue
+		}
+		res[name] = pathOf(err)
+	}
+	if err := testhelp.ValidateResults(res, expects); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	debugPath := filepath.Join(dir, "debug.log")
+	errorPath := filepath.Join(dir, "error.log")
+	spec, err := Parse([]byte(`
+level: err
+format: {name: logfmt, time_format: "2006"}
+sinks:
+  - output: {type: file, path: ` + errorPath + `}
+  - level: debug
+    format: {name: json, timestamp_key: "-"}
+    output: {type: file, path: ` + debugPath + `}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := spec.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.Log(logf.Error, "failure", logf.String("a", "b"))
+	tree.Log(logf.Debug, "details")
+	if err := tree.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	errorLog, _ := os.ReadFile(errorPath)
+	if !strings.HasPrefix(string(errorLog), "level=err timestamp=") || !strings.HasSuffix(string(errorLog), " message=failure a=b\n") {
+		t.Errorf("wrong error log: %q", errorLog)
+	}
+	debugLog, _ := os.ReadFile(debugPath)
+	wanted := `{"level":3,"level_str":"err","message":"failure","props":{"a":"b"}}` + "\n" +
+		`{"level":7,"level_str":"debug","message":"details"}` + "\n"
+	if string(debugLog) != wanted {
+		t.Errorf("wrong debug log: %q", debugLog)
+	}
+}
+
+func TestBuildSyslog(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	spec := &Spec{Output: OutputSpec{Type: "syslog", Address: conn.LocalAddr().String()}}
+	tree, err := spec.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	tree.Log(logf.Warning, "over udp")
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := string(buf[:n]); !strings.HasPrefix(msg, "<12>1 ") || !strings.Contains(msg, " over udp") {
+		t.Errorf("wrong syslog message: %q", msg)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	tests := map[string]*Spec{
+		"level":         {Level: "loud"},
+		"sink_level":    {Sinks: []SinkSpec{{}, {DefaultLevel: "nope"}}},
+		"format":        {Format: FormatSpec{Name: "xml"}},
+		"sink_format":   {Sinks: []SinkSpec{{}, {}, {Format: FormatSpec{Name: "json", Options: map[string]any{"indent": 2}}}}},
+		"inherited":     {Format: FormatSpec{Name: "json", Options: map[string]any{"nope": 1}}, Sinks: []SinkSpec{{}}},
+		"output":        {Sinks: []SinkSpec{{Output: OutputSpec{Type: "pipe"}}}},
+		"output_type":   {Sinks: []SinkSpec{{Output: OutputSpec{Path: "x"}}}},
+		"file_path":     {Output: OutputSpec{Type: "file"}},
+		"buffer":        {Output: OutputSpec{Type: "file", Path: "x", Buffer: 300}},
+		"syslog":        {Output: OutputSpec{Type: "syslog", Network: "bogus", Address: "x"}},
+		"missing_file":  {Output: OutputSpec{Type: "file", Path: "/nonexistent/dir/x.log"}},
+		"template_bad":  {Format: FormatSpec{Name: "template", Options: map[string]any{"template": "{{.Nope}}"}}},
+		"options_types": {Format: FormatSpec{Name: "emf", Options: map[string]any{"metrics": []any{map[string]any{"unit": 5}}}}},
+	}
+	expects := testhelp.ResultsMap{
+		"level":         "level",
+		"sink_level":    "sinks[1].default_level",
+		"format":        "format.name",
+		"sink_format":   "sinks[2].format.indent",
+		"inherited":     "format.nope",
+		"output":        "sinks[0].output.type",
+		"output_type":   "sinks[0].output.type",
+		"file_path":     "output.path",
+		"buffer":        "output.buffer",
+		"syslog":        "output.address",
+		"missing_file":  "output.path",
+		"template_bad":  "format",
+		"options_types": "format.metrics[0].unit",
+	}
+	res := testhelp.ResultsMap{}
+	for name, spec := range tests {
+		_, err := spec.Build()
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+			continue
+		}
+		res[name] = pathOf(err)
+	}
+	if err := testhelp.ValidateResults(res, expects); err != nil {
+		t.Error(err)
+	}
+
+	_, err := (&Spec{Sinks: []SinkSpec{{}, {}, {Format: FormatSpec{Name: "json", Options: map[string]any{"indent": 2}}}}}).Build()
+	if !errors.Is(err, formats.OptionTypeError) || err.Error() != "sinks[2].format.indent: wrong option type: can't use int for string" {
+		t.Errorf("wrong error message: %v", err)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	spec, err := fromEnv([]string{
+		"PATH=/bin",
+		"LOGF_LEVEL=debug",
+		"LOGF_FORMAT=json",
+		"LOGF_FORMAT_FLATTEN=true",
+		"LOGF_FORMAT_TIME_FORMAT=15:04",
+		"LOGF_SINKS_0_OUTPUT=stderr",
+		"LOGF_SINKS_1_OUTPUT=file",
+		"LOGF_SINKS_1_OUTPUT_PATH=/tmp/app.log",
+		"LOGF_SINKS_1_OUTPUT_BUFFER=8",
+		"LOGF_SINKS_1_FORMAT=syslog5424",
+		"LOGF_SINKS_1_FORMAT_APP_NAME='123'",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Level != "debug" || spec.Format.Name != "json" || spec.Format.Options["flatten"] != true ||
+		spec.Format.Options["time_format"] != "15:04" || len(spec.Sinks) != 2 || spec.Sinks[0].Output.Type != "stderr" {
+		t.Errorf("wrong spec: %+v", spec)
+	}
+	if sink := spec.Sinks[1]; sink.Output != (OutputSpec{Type: "file", Path: "/tmp/app.log", Buffer: 8}) ||
+		sink.Format.Name != "syslog5424" || sink.Format.Options["app_name"] != "123" {
+		t.Errorf("wrong sink: %+v", sink)
+	}
+
+	for _, env := range []string{"LOGF_NOPE=1", "LOGF_SINKS_X_LEVEL=info", "LOGF_FORMAT_INDENT=2"} {
+		if _, err := fromEnv([]string{env}); err == nil {
+			t.Errorf("expected error for %s", env)
+		}
+	}
+
+	_, err = fromEnv([]string{"LOGF_SINKS_1_OUTPUT=stderr"})
+	var pathErr *PathError
+	if !errors.As(err, &pathErr) || pathErr.Path != "LOGF_SINKS_0" || !errors.Is(err, MissingValueError) {
+		t.Errorf("wrong error for a missing sink: %v", err)
+	}
+}
+
+func TestBuildConsoleFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "console.log")
+	spec := &Spec{
+		Format: FormatSpec{Name: "console", Options: map[string]any{"color": "auto"}},
+		Output: OutputSpec{Type: "file", Path: path},
+	}
+	tree, err := spec.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.Log(logf.Warning, "to a file")
+	tree.Close()
+	out, _ := os.ReadFile(path)
+	if !strings.Contains(string(out), "to a file") || strings.Contains(string(out), "\x1b[") {
+		t.Errorf("wanted console output without colors in a file, got %q", out)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logconfig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+var WatcherClosedError = errors.New("config watcher is closed")
+
+// WatchConfig configures Watch.
+// Usage notes:
+//   - Interval is how often the file is checked for changes, and defaults to 2 seconds
+//   - Use installs each new tree, and defaults to logf.Use
+//   - OnReload is called with the new spec after its tree is installed
+//   - OnError is called when a changed config can't be loaded. The running tree keeps logging,
+//     and the error is reported once per change to the file. Errors are dropped if it's nil.
+type WatchConfig struct {
+	Interval time.Duration
+	Use      func(logf.Logger)
+	OnReload func(*Spec)
+	OnError  func(error)
+}
+
+func (conf WatchConfig) withDefaults() WatchConfig {
+	if conf.Interval <= 0 {
+		conf.Interval = 2 * time.Second
+	}
+	if conf.Use == nil {
+		conf.Use = func(log logf.Logger) {
+			logf.Use(log)
+		}
+	}
+	return conf
+}
+
+// Watcher keeps the active logger in sync with a config file.
+type Watcher struct {
+	path string
+	conf WatchConfig
+
+	// mu serializes reloads, and guards the fields below.
+	mu      sync.Mutex
+	tree    *Tree
+	data    []byte
+	modTime time.Time
+	size    int64
+	sum     [sha256.Size]byte
+	closed  bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Watch loads the config file at path, installs its tree with conf.Use, and polls the file for
+// changes.
+// When the file changes, a new tree is built and swapped in, and the old tree is closed once its
+// in-flight writes finish. A config that doesn't parse or build is rejected, leaving the running
+// tree in place. Editors that rewrite a file in place can be caught mid-write; the rejected
+// partial file is reported, and the complete file is loaded on the next poll.
+//
+// Watch returns an error, without starting, if the initial config can't be loaded.
+func Watch(path string, conf WatchConfig) (*Watcher, error) {
+	w := &Watcher{
+		path: path,
+		conf: conf.withDefaults(),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	w.stat()
+	if err := w.Reload(); err != nil {
+		return nil, err
+	}
+	go w.work()
+	return w, nil
+}
+
+// Reload loads the config file now, if its contents changed since the last load.
+// On error, the running tree is left in place.
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return WatcherClosedError
+	}
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return err
+	}
+	if w.tree != nil && bytes.Equal(data, w.data) {
+		return nil
+	}
+	spec, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	tree, err := spec.Build()
+	if err != nil {
+		return err
+	}
+	w.conf.Use(tree)
+	old := w.tree
+	w.tree, w.data = tree, data
+	if old != nil {
+		old.Close()
+	}
+	if w.conf.OnReload != nil {
+		w.conf.OnReload(spec)
+	}
+	return nil
+}
+
+// Tree returns the tree built from the last config that loaded.
+func (w *Watcher) Tree() *Tree {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.tree
+}
+
+// Close stops watching, and closes the current tree.
+// Install another logger first if anything still logs through it.
+func (w *Watcher) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return WatcherClosedError
+	}
+	w.closed = true
+	w.mu.Unlock()
+	close(w.stop)
+	<-w.done
+	return w.tree.Close()
+}
+
+func (w *Watcher) work() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.conf.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.stop:
+			return
+		}
+		changed, err := w.stat()
+		if !changed {
+			continue
+		}
+		if err == nil {
+			err = w.Reload()
+		}
+		if err != nil && !errors.Is(err, WatcherClosedError) && w.conf.OnError != nil {
+			w.conf.OnError(err)
+		}
+	}
+}
+
+// stat reports whether the file's modification time, size, or contents changed since the last
+// call. Contents are compared by hash, since an edit that keeps the size can land within the
+// filesystem's modification time resolution. A file that can't be read counts as changed once.
+func (w *Watcher) stat() (bool, error) {
+	info, err := os.Stat(w.path)
+	modTime, size, sum := time.Time{}, int64(-1), [sha256.Size]byte{}
+	if err == nil {
+		modTime, size = info.ModTime(), info.Size()
+		var data []byte
+		if data, err = os.ReadFile(w.path); err == nil {
+			sum = sha256.Sum256(data)
+		}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	changed := !modTime.Equal(w.modTime) || size != w.size || sum != w.sum
+	w.modTime, w.size, w.sum = modTime, size, sum
+	return changed, err
+}
@@ -0,0 +1,161 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logconfig
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+// writeConfig writes a config file with a distinct modification time, so the watcher sees the
+// change even on filesystems with coarse timestamps.
+func writeConfig(t *testing.T, path, config string, version int) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stamp := time.Unix(int64(1700000000+version), 0)
+	if err := os.Chtimes(path, stamp, stamp); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "logging.yaml")
+	firstPath := filepath.Join(dir, "first.log")
+	secondPath := filepath.Join(dir, "second.log")
+	writeConfig(t, configPath, "level: err\nformat: {name: json, timestamp_key: \"-\"}\noutput: {type: file, path: "+firstPath+"}\n", 1)
+
+	defer logf.Use(logf.Active())
+	reloads := make(chan *Spec, 1)
+	errs := make(chan error, 1)
+	w, err := Watch(configPath, WatchConfig{
+		Interval: 5 * time.Millisecond,
+		OnReload: func(spec *Spec) { reloads <- spec },
+		OnError:  func(err error) { errs <- err },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-reloads
+	defer w.Close()
+
+	// Log from another goroutine across the swap; every message must land in one of the files.
+	stop := make(chan struct{})
+	logged := make(chan int)
+	go func() {
+		n := 0
+		for {
+			select {
+			case <-stop:
+				logged <- n
+				return
+			default:
+			}
+			if err := logf.Log(logf.Error, "busy"); err == nil {
+				n++
+			} else if !errors.Is(err, TreeClosedError) {
+				t.Error(err)
+			}
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	writeConfig(t, configPath, "level: debug\nformat: {name: json, timestamp_key: \"-\"}\noutput: {type: file, path: "+secondPath+"}\n", 2)
+	select {
+	case spec := <-reloads:
+		if spec.Level != "debug" {
+			t.Errorf("reloaded wrong spec: %+v", spec)
+		}
+	case err := <-errs:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("config change wasn't picked up")
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(stop)
+	busy := <-logged
+
+	writeConfig(t, configPath, "level: loud\n", 3)
+	select {
+	case <-reloads:
+		t.Fatal("bad config was installed")
+	case err := <-errs:
+		var pathErr *PathError
+		if !errors.As(err, &pathErr) || pathErr.Path != "level" || !errors.Is(err, UnknownLevelError) {
+			t.Errorf("wrong error for bad config: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("bad config wasn't reported")
+	}
+	if err := logf.Log(logf.Debug, "still here"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := os.ReadFile(firstPath)
+	second, _ := os.ReadFile(secondPath)
+	if total := strings.Count(string(first)+string(second), `"message":"busy"`); total != busy {
+		t.Errorf("%d of %d messages were written across the swap", total, busy)
+	}
+	if !strings.HasSuffix(string(second), `{"level":7,"level_str":"debug","message":"still here"}`+"\n") {
+		t.Errorf("running tree changed after a bad config: %q", second)
+	}
+}
+
+func TestWatchBadConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "logging.yaml")
+	writeConfig(t, configPath, "output: {type: pipe}\n", 1)
+	_, err := Watch(configPath, WatchConfig{Use: func(logf.Logger) { t.Error("bad config was installed") }})
+	if !errors.Is(err, UnknownOutputError) {
+		t.Errorf("got %v, want %v", err, UnknownOutputError)
+	}
+}
+
+func TestWatchSameSizeEdit(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "logging.yaml")
+	writeConfig(t, configPath, "level: 3\n", 1)
+	reloads := make(chan *Spec, 2)
+	w, err := Watch(configPath, WatchConfig{
+		Interval: 5 * time.Millisecond,
+		Use:      func(logf.Logger) {},
+		OnReload: func(spec *Spec) { reloads <- spec },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	<-reloads
+
+	// Same size and the same modification time, so only the contents show the change.
+	writeConfig(t, configPath, "level: 7\n", 1)
+	select {
+	case spec := <-reloads:
+		if spec.Level != "7" {
+			t.Errorf("reloaded wrong spec: %+v", spec)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("same-size edit wasn't picked up")
+	}
+}
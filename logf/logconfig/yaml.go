@@ -0,0 +1,500 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logconfig
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var YAMLSyntaxError = errors.New("invalid YAML")
+
+// yamlLine is a non-blank line with its comment removed.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// parseYAML parses the subset of YAML used by config files:
+//   - Block mappings and sequences, nested by indentation with spaces
+//   - Plain, single-quoted, and double-quoted scalars, and | and > block scalars
+//   - Flow sequences and mappings, like [a, b] and {a: 1}, on a single line
+//   - Comments, and a leading --- document marker
+//
+// Anchors, aliases, tags, multi-line plain or flow scalars, and multiple documents aren't
+// supported. Mappings are map[string]any and sequences are []any; scalars are nil, bool, int64,
+// float64, or string.
+func parseYAML(data string) (any, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		text := strings.TrimRight(raw, " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed[0] == '#' {
+			p.lines = append(p.lines, yamlLine{num: i + 1, indent: -1, text: text})
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("%w: line %d: tabs can't be used for indentation", YAMLSyntaxError, i+1)
+		}
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) && p.lines[p.pos].indent == 0 && p.lines[p.pos].text == "---" {
+		p.pos++
+		p.skipBlank()
+	}
+	if p.pos == len(p.lines) {
+		return nil, nil
+	}
+	value, err := p.parseBlock(p.lines[p.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		return nil, p.errorf("unexpected content")
+	}
+	return value, nil
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// skipBlank skips blank and comment lines, which have an indent of -1.
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && p.lines[p.pos].indent < 0 {
+		p.pos++
+	}
+}
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	num := 0
+	if p.pos < len(p.lines) {
+		num = p.lines[p.pos].num
+	} else if len(p.lines) > 0 {
+		num = p.lines[len(p.lines)-1].num
+	}
+	return fmt.Errorf("%w: line %d: %s", YAMLSyntaxError, num, fmt.Sprintf(format, args...))
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseBlock parses the block node that starts on the current line, at indent.
+func (p *yamlParser) parseBlock(indent int) (any, error) {
+	line := p.lines[p.pos]
+	if isSeqItem(line.text) {
+		return p.parseSeq(indent)
+	}
+	if _, _, ok, err := splitKey(line.text); err != nil {
+		return nil, p.errorf("%v", err)
+	} else if ok {
+		return p.parseMap(indent)
+	}
+	p.pos++
+	return parseInline(line.text)
+}
+
+func (p *yamlParser) parseSeq(indent int) (any, error) {
+	list := []any{}
+	for {
+		p.skipBlank()
+		if p.pos == len(p.lines) || p.lines[p.pos].indent != indent || !isSeqItem(p.lines[p.pos].text) {
+			return list, nil
+		}
+		line := p.lines[p.pos]
+		content := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		var item any
+		var err error
+		if content == "" || content[0] == '#' {
+			p.pos++
+			item, err = p.parseNested(indent, false)
+		} else {
+			// Parse the item as if "- " were indentation, so "- key: value" starts a mapping.
+			p.lines[p.pos] = yamlLine{num: line.num, indent: indent + len(line.text) - len(content), text: content}
+			item, err = p.parseBlock(p.lines[p.pos].indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+}
+
+func (p *yamlParser) parseMap(indent int) (any, error) {
+	obj := map[string]any{}
+	for {
+		p.skipBlank()
+		if p.pos == len(p.lines) || p.lines[p.pos].indent < indent {
+			return obj, nil
+		}
+		line := p.lines[p.pos]
+		if line.indent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		if isSeqItem(line.text) {
+			return obj, nil
+		}
+		key, rest, ok, err := splitKey(line.text)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if !ok {
+			return nil, p.errorf("expected key: value")
+		}
+		if _, dup := obj[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		p.pos++
+		if strings.HasPrefix(rest, "#") {
+			rest = ""
+		}
+		var value any
+		switch {
+		case rest == "":
+			value, err = p.parseNested(indent, true)
+		case rest[0] == '|' || rest[0] == '>':
+			value, err = p.parseBlockScalar(indent, rest)
+		default:
+			value, err = parseInline(rest)
+			if err != nil {
+				p.pos--
+				err = p.errorf("%v", err)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		obj[key] = value
+	}
+}
+
+// parseNested parses the value of a key or item with nothing after it on its line: a block on
+// the following lines, or null. A mapping's value may be a sequence at the mapping's indent.
+func (p *yamlParser) parseNested(indent int, inMap bool) (any, error) {
+	p.skipBlank()
+	if p.pos == len(p.lines) {
+		return nil, nil
+	}
+	next := p.lines[p.pos]
+	if next.indent > indent || (inMap && next.indent == indent && isSeqItem(next.text)) {
+		return p.parseBlock(next.indent)
+	}
+	return nil, nil
+}
+
+func (p *yamlParser) parseBlockScalar(indent int, header string) (any, error) {
+	folded := header[0] == '>'
+	chomp := strings.TrimSpace(header[1:])
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return nil, p.errorf("unsupported block scalar header %q", header)
+	}
+	lines := []string{}
+	blockIndent := -1
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent >= 0 && line.indent <= indent {
+			break
+		}
+		if line.indent < 0 {
+			// Blank lines are kept, but comment-looking lines inside the block are content.
+			if strings.TrimSpace(line.text) == "" {
+				lines = append(lines, "")
+				p.pos++
+				continue
+			}
+			text := strings.TrimLeft(line.text, " ")
+			line.indent = len(line.text) - len(text)
+			line.text = text
+			if line.indent <= indent {
+				break
+			}
+		}
+		if blockIndent < 0 {
+			blockIndent = line.indent
+		}
+		if line.indent < blockIndent {
+			return nil, p.errorf("bad block scalar indentation")
+		}
+		lines = append(lines, strings.Repeat(" ", line.indent-blockIndent)+line.text)
+		p.pos++
+	}
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var out string
+	if folded {
+		var b strings.Builder
+		for i, line := range lines {
+			// Line breaks fold to spaces, except around blank and more-indented lines.
+			switch {
+			case i == 0:
+			case line == "":
+				b.WriteByte('\n')
+			case lines[i-1] == "":
+			case strings.HasPrefix(line, " ") || strings.HasPrefix(lines[i-1], " "):
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(' ')
+			}
+			b.WriteString(line)
+		}
+		out = b.String()
+	} else {
+		out = strings.Join(lines, "\n")
+	}
+	switch chomp {
+	case "-":
+	case "+":
+		out += strings.Repeat("\n", trailing+1)
+	default:
+		if len(lines) > 0 {
+			out += "\n"
+		}
+	}
+	return out, nil
+}
+
+// splitKey splits "key: value" or "key:". ok is false if text isn't a mapping entry.
+func splitKey(text string) (key, rest string, ok bool, err error) {
+	if text[0] == '"' || text[0] == '\'' {
+		end, err := quoteEnd(text, 0)
+		if err != nil {
+			return "", "", false, err
+		}
+		after := text[end+1:]
+		if after != ":" && !strings.HasPrefix(after, ": ") {
+			return "", "", false, nil
+		}
+		key, err := unquote(text[:end+1])
+		return key, strings.TrimSpace(after[1:]), err == nil, err
+	}
+	if text[0] == '[' || text[0] == '{' {
+		return "", "", false, nil
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true, nil
+		}
+		if text[i] == ' ' && i+1 < len(text) && text[i+1] == '#' {
+			break
+		}
+	}
+	return "", "", false, nil
+}
+
+// parseInline parses a value on a single line: a flow collection or a scalar, with an optional
+// trailing comment.
+func parseInline(text string) (any, error) {
+	f := &flowParser{text: text}
+	value, err := f.parseValue(true)
+	if err != nil {
+		return nil, err
+	}
+	f.skipSpace()
+	if f.pos < len(f.text) && !strings.HasPrefix(f.text[f.pos:], "#") {
+		return nil, fmt.Errorf("unexpected %q", f.text[f.pos:])
+	}
+	return value, nil
+}
+
+type flowParser struct {
+	text string
+	pos  int
+}
+
+func (f *flowParser) skipSpace() {
+	for f.pos < len(f.text) && f.text[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+// parseValue parses a scalar or flow collection. top is false inside flow collections, where
+// scalars end at , ] and }.
+func (f *flowParser) parseValue(top bool) (any, error) {
+	f.skipSpace()
+	if f.pos == len(f.text) || (top && f.text[f.pos] == '#') {
+		return nil, nil
+	}
+	switch c := f.text[f.pos]; c {
+	case '[':
+		return f.parseFlowSeq()
+	case '{':
+		return f.parseFlowMap()
+	case '"', '\'':
+		end, err := quoteEnd(f.text, f.pos)
+		if err != nil {
+			return nil, err
+		}
+		str, err := unquote(f.text[f.pos : end+1])
+		f.pos = end + 1
+		return str, err
+	case '&', '*', '!', '|', '>', '%', '@', '`':
+		return nil, fmt.Errorf("unsupported YAML syntax %q", c)
+	}
+	start := f.pos
+	for f.pos < len(f.text) {
+		c := f.text[f.pos]
+		if c == '#' && f.pos > start && f.text[f.pos-1] == ' ' {
+			break
+		}
+		if !top && (c == ',' || c == ']' || c == '}' || (c == ':' && (f.pos+1 == len(f.text) || f.text[f.pos+1] == ' '))) {
+			break
+		}
+		f.pos++
+	}
+	return resolveScalar(strings.TrimSpace(f.text[start:f.pos])), nil
+}
+
+func (f *flowParser) parseFlowSeq() (any, error) {
+	f.pos++
+	list := []any{}
+	for {
+		f.skipSpace()
+		if f.pos == len(f.text) {
+			return nil, errors.New("unterminated [")
+		}
+		if f.text[f.pos] == ']' {
+			f.pos++
+			return list, nil
+		}
+		item, err := f.parseValue(false)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+		if err := f.flowSeparator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *flowParser) parseFlowMap() (any, error) {
+	f.pos++
+	obj := map[string]any{}
+	for {
+		f.skipSpace()
+		if f.pos == len(f.text) {
+			return nil, errors.New("unterminated {")
+		}
+		if f.text[f.pos] == '}' {
+			f.pos++
+			return obj, nil
+		}
+		key, err := f.parseValue(false)
+		if err != nil {
+			return nil, err
+		}
+		f.skipSpace()
+		if f.pos == len(f.text) || f.text[f.pos] != ':' {
+			return nil, errors.New("expected : in flow mapping")
+		}
+		f.pos++
+		value, err := f.parseValue(false)
+		if err != nil {
+			return nil, err
+		}
+		name := fmt.Sprint(key)
+		if _, dup := obj[name]; dup {
+			return nil, fmt.Errorf("duplicate key %q", name)
+		}
+		obj[name] = value
+		if err := f.flowSeparator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// flowSeparator consumes the , after a flow item, leaving the closing bracket.
+func (f *flowParser) flowSeparator(closing byte) error {
+	f.skipSpace()
+	if f.pos < len(f.text) && f.text[f.pos] == ',' {
+		f.pos++
+		return nil
+	}
+	if f.pos < len(f.text) && f.text[f.pos] == closing {
+		return nil
+	}
+	return fmt.Errorf("expected , or %c", closing)
+}
+
+// quoteEnd returns the index of the quote that closes the quoted scalar at text[start].
+func quoteEnd(text string, start int) (int, error) {
+	quote := text[start]
+	for i := start + 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i, nil
+		}
+	}
+	return 0, errors.New("unterminated quoted scalar")
+}
+
+func unquote(quoted string) (string, error) {
+	if quoted[0] == '\'' {
+		return strings.ReplaceAll(quoted[1:len(quoted)-1], "''", "'"), nil
+	}
+	str, err := strconv.Unquote(quoted)
+	if err != nil {
+		return "", fmt.Errorf("bad escape in %s", quoted)
+	}
+	return str, nil
+}
+
+// resolveScalar gives a plain scalar its YAML 1.2 core schema type.
+func resolveScalar(str string) any {
+	switch str {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return math.Inf(1)
+	case "-.inf", "-.Inf", "-.INF":
+		return math.Inf(-1)
+	case ".nan", ".NaN", ".NAN":
+		return math.NaN()
+	}
+	if i, err := strconv.ParseInt(str, 10, 64); err == nil {
+		return i
+	}
+	if strings.HasPrefix(str, "0x") || strings.HasPrefix(str, "0o") {
+		if i, err := strconv.ParseInt(str, 0, 64); err == nil {
+			return i
+		}
+	}
+	if c := str[0]; c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9') {
+		if f, err := strconv.ParseFloat(str, 64); err == nil && !strings.ContainsAny(str, "_xXpP") {
+			return f
+		}
+	}
+	return str
+}
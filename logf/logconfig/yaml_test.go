@@ -0,0 +1,118 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/decentplatforms/appkit/logf/testhelp"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := map[string]string{
+		"scalars": `
+a: plain text
+b: 12
+c: -1.5
+d: true
+e: ~
+f: "quoted: \"x\"\n"
+g: 'it''s'
+h: 0x10
+i: "123"
+j: value # comment
+`,
+		"nested": `---
+# leading comment
+top:
+  child:
+    leaf: 1
+  other: 2
+list:
+- a
+- b
+`,
+		"seq_maps": `
+sinks:
+  - output: stdout
+    level: debug
+  - format:
+      name: kv
+    output: {type: file, path: "/tmp/x.log"}
+  -
+    level: 3
+  - - nested
+    - list
+`,
+		"flow": `a: [1, two, "three", [4], {five: 5}]
+b: {x: 1, "y z": [a, b]}
+c: []
+`,
+		"block": `
+literal: |
+  line one
+    indented
+  line three
+folded: >-
+  folded
+  text
+
+  new paragraph
+after: x
+`,
+		"empty": "# nothing here\n",
+	}
+	expects := testhelp.ResultsMap{
+		"scalars":  `{"a":"plain text","b":12,"c":-1.5,"d":true,"e":null,"f":"quoted: \"x\"\n","g":"it's","h":16,"i":"123","j":"value"}`,
+		"nested":   `{"list":["a","b"],"top":{"child":{"leaf":1},"other":2}}`,
+		"seq_maps": `{"sinks":[{"level":"debug","output":"stdout"},{"format":{"name":"kv"},"output":{"path":"/tmp/x.log","type":"file"}},{"level":3},["nested","list"]]}`,
+		"flow":     `{"a":[1,"two","three",[4],{"five":5}],"b":{"x":1,"y z":["a","b"]},"c":[]}`,
+		"block":    `{"after":"x","folded":"folded text\nnew paragraph","literal":"line one\n  indented\nline three\n"}`,
+		"empty":    `null`,
+	}
+	res := testhelp.ResultsMap{}
+	for name, doc := range tests {
+		value, err := parseYAML(doc)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		raw, _ := json.Marshal(value)
+		res[name] = string(raw)
+	}
+	if err := testhelp.ValidateResults(res, expects); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	for _, doc := range []string{
+		"a: 1\na: 2",
+		"a: 1\n  b: 2",
+		"a: [1, 2",
+		"a: \"unterminated",
+		"a: &anchor 1",
+		"a: *alias",
+		"a:\n\t- tab",
+		"a: {b 1}",
+		"- a\nb: 1",
+	} {
+		if _, err := parseYAML(doc); !errors.Is(err, YAMLSyntaxError) {
+			t.Errorf("expected YAMLSyntaxError for %q, got %v", doc, err)
+		}
+	}
+}
@@ -50,13 +50,29 @@ func (log *logger) Log(level LogLevel, msg string, props ...Prop) error {
 		return nil
 	}
 	logProps := NewProps(props...)
-	out := log.Format.FormatAndNormalize(level, msg, logProps)
+	if log.AddCaller && logProps.Get(SOURCE) == nil {
+		if frame, ok := caller(log.CallerSkip); ok {
+			logProps.Set(Prop{Name: SOURCE, Value: frame})
+		}
+	}
+	if log.AddStack && level <= log.Stack.Level && logProps.Get(STACK) == nil {
+		logProps.Set(Prop{Name: STACK, Value: stack(log.Stack)})
+	}
+	out := log.format(level, msg, logProps)
 	_, err := log.Output.Write([]byte(out))
 	logProps.Return()
 	return err
 }
 
 func (log *logger) Write(msg []byte) (n int, err error) {
-	out := log.Format.FormatAndNormalize(log.DefaultLevel, string(msg), NewProps())
+	logProps := NewProps()
+	out := log.format(log.DefaultLevel, string(msg), logProps)
+	logProps.Return()
 	return log.Output.Write([]byte(out))
 }
+
+func (log *logger) format(level LogLevel, msg string, props *Props) string {
+	view := props.View()
+	view.names = log.LevelNames
+	return log.Format.formatView(level, msg, view)
+}
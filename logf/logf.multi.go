@@ -22,6 +22,12 @@ type multiLogger struct {
 	logs []Logger
 }
 
+// NewMultiLogger returns a logger that fans each message out to all of logs, in order.
+// Errors from the subloggers are joined, and a failing sublogger doesn't stop the others.
+func NewMultiLogger(logs ...Logger) Logger {
+	return &multiLogger{logs: append([]Logger{}, logs...)}
+}
+
 func (log *multiLogger) Configure(conf Config) error {
 	return MultiConfigError
 }
@@ -30,7 +36,7 @@ func (log *multiLogger) Log(level LogLevel, msg string, props ...Prop) error {
 	var err error
 	for _, log := range log.logs {
 		logErr := log.Log(level, msg, props...)
-		errors.Join(err, logErr)
+		err = errors.Join(err, logErr)
 	}
 	return err
 }
@@ -38,7 +44,7 @@ func (log *multiLogger) Log(level LogLevel, msg string, props ...Prop) error {
 func (log *multiLogger) Write(msg []byte) (n int, err error) {
 	for _, log := range log.logs {
 		_, logErr := log.Write(msg)
-		errors.Join(err, logErr)
+		err = errors.Join(err, logErr)
 	}
 	return len(msg), err
 }
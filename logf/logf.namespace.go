@@ -0,0 +1,89 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logf
+
+import (
+	"maps"
+	"sync/atomic"
+)
+
+// reservedProps are the names of props that Namespace leaves at the top level. Like keywords,
+// the set is replaced, never changed, so it's safe to read while ReserveProps runs.
+var reservedProps atomic.Pointer[map[string]bool]
+
+func init() {
+	ReserveProps(ERROR, SOURCE, STACK)
+}
+
+// ReserveProps marks props that formats read by name, like ERROR or a trace ID, so loggers from
+// Namespace keep them at the top level instead of in the namespace's group.
+// ERROR, SOURCE, and STACK are reserved, and package formats reserves the props it reads, like
+// formats.OTEL_TRACE_ID and the syslog headers.
+func ReserveProps(names ...string) {
+	for {
+		current := reservedProps.Load()
+		next := map[string]bool{}
+		if current != nil {
+			next = maps.Clone(*current)
+		}
+		for _, name := range names {
+			next[name] = true
+		}
+		if reservedProps.CompareAndSwap(current, &next) {
+			return
+		}
+	}
+}
+
+type namespaceLogger struct {
+	log  Logger
+	name string
+}
+
+// Namespace returns a child of log that puts the props of each Log call in a Group named name,
+// so Namespace(log, "http").Log(level, msg, String("method", "GET")) logs http.method.
+// Usage notes:
+//   - Namespaces nest, so Namespace(Namespace(log, "http"), "client") logs http.client.x
+//   - Props marked with ReserveProps, like ERROR, stay at the top level, so formats still find them
+//   - Calls without other props log no group
+//   - Configure and Write go to log unchanged
+func Namespace(log Logger, name string) Logger {
+	return &namespaceLogger{log: log, name: name}
+}
+
+func (log *namespaceLogger) Configure(conf Config) error {
+	return log.log.Configure(conf)
+}
+
+func (log *namespaceLogger) Log(level LogLevel, msg string, props ...Prop) error {
+	reserved := *reservedProps.Load()
+	top := make([]Prop, 0, len(props)+1)
+	grouped := make([]Prop, 0, len(props))
+	for _, prop := range props {
+		if reserved[prop.Name] {
+			top = append(top, prop)
+		} else {
+			grouped = append(grouped, prop)
+		}
+	}
+	if len(grouped) > 0 {
+		top = append(top, Group(log.name, grouped...))
+	}
+	return log.log.Log(level, msg, top...)
+}
+
+func (log *namespaceLogger) Write(msg []byte) (n int, err error) {
+	return log.log.Write(msg)
+}
@@ -0,0 +1,177 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logf_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/decentplatforms/appkit/logf"
+	"github.com/decentplatforms/appkit/logf/testhelp"
+)
+
+// sourceFormat writes the SOURCE prop's function, file, and line.
+func sourceFormat(level logf.LogLevel, msg string, props logf.PropsView) string {
+	frame, ok := props.Get(logf.SOURCE).(logf.Frame)
+	if !ok {
+		return "none"
+	}
+	fn := strings.TrimPrefix(frame.Function, "github.com/decentplatforms/appkit/logf_test.")
+	// Closures are named like TestAddCaller.func2; the number depends on their order in the file.
+	fn, _, _ = strings.Cut(fn, ".func")
+	return fmt.Sprintf("%s %s:%d", fn, filepath.Base(frame.File), frame.Line)
+}
+
+// logHelper wraps Log, like an application's logging helper would.
+func logHelper(log logf.Logger) {
+	log.Log(logf.Error, "from helper")
+}
+
+func TestAddCaller(t *testing.T) {
+	var out strings.Builder
+	conf := logf.Config{MaxLevel: logf.Debug, Format: sourceFormat, Output: &out, AddCaller: true}
+	log, _ := logf.NewLogger(conf)
+	conf.CallerSkip = 1
+	skipping, _ := logf.NewLogger(conf)
+	conf.AddCaller = false
+	off, _ := logf.NewLogger(conf)
+
+	res := testhelp.ResultsMap{}
+	wanted := testhelp.ResultsMap{}
+	capture := func(name string, call func()) {
+		out.Reset()
+		_, _, line, _ := runtime.Caller(1)
+		call()
+		res[name] = strings.TrimSpace(out.String())
+		wanted[name] = fmt.Sprintf("TestAddCaller logf_test.go:%d", line)
+	}
+
+	capture("direct", func() { log.Log(logf.Error, "direct") })
+	capture("multi", func() { logf.NewMultiLogger(log).Log(logf.Error, "multi") })
+	capture("namespace", func() { logf.Namespace(log, "ns").Log(logf.Error, "namespace") })
+	previous := logf.Use(log)
+	capture("global", func() { logf.Log(logf.Error, "global") })
+	logf.Use(previous)
+	capture("skip", func() { logHelper(skipping) })
+	if err := testhelp.ValidateResults(res, wanted); err != nil {
+		t.Error(err)
+	}
+
+	out.Reset()
+	log.Log(logf.Error, "explicit", logf.Prop{Name: logf.SOURCE, Value: logf.Frame{Function: "pkg.Set", File: "set.go", Line: 1}})
+	off.Log(logf.Error, "off")
+	if out.String() != "pkg.Set set.go:1\nnone\n" {
+		t.Errorf("wrong explicit and disabled sources: %q", out.String())
+	}
+}
+
+func TestAddStack(t *testing.T) {
+	var stacks [][]logf.Frame
+	format := func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		frames, _ := props.Get(logf.STACK).([]logf.Frame)
+		stacks = append(stacks, frames)
+		return msg
+	}
+	conf := logf.Config{MaxLevel: logf.Debug, Format: format, Output: io.Discard, AddStack: true, Stack: logf.StackOptions{Level: logf.Critical}}
+	log, _ := logf.NewLogger(conf)
+	conf.Stack = logf.StackOptions{Level: logf.Critical, MaxDepth: 1}
+	shallow, _ := logf.NewLogger(conf)
+	conf.Stack = logf.StackOptions{Level: logf.Critical, KeepRuntime: true, KeepLogf: true}
+	untrimmed, _ := logf.NewLogger(conf)
+
+	log.Log(logf.Error, "not severe enough")
+	logf.NewMultiLogger(log).Log(logf.Critical, "through a wrapper")
+	shallow.Log(logf.Alert, "shallow")
+	logf.NewMultiLogger(untrimmed).Log(logf.Critical, "untrimmed")
+
+	function := func(frame logf.Frame) string {
+		return frame.Function[strings.LastIndexByte(frame.Function, '/')+1:]
+	}
+	res := testhelp.ResultsMap{
+		"below":            fmt.Sprint(len(stacks[0])),
+		"first":            function(stacks[1][0]),
+		"trimmed":          fmt.Sprint(!strings.HasPrefix(function(stacks[1][len(stacks[1])-1]), "runtime.")),
+		"shallow":          fmt.Sprint(len(stacks[2])),
+		"untrimmed_first":  function(stacks[3][0]),
+		"untrimmed_bottom": function(stacks[3][len(stacks[3])-1]),
+	}
+	wanted := testhelp.ResultsMap{
+		"below":            "0",
+		"first":            "logf_test.TestAddStack",
+		"trimmed":          "true",
+		"shallow":          "1",
+		"untrimmed_first":  "logf.(*multiLogger).Log",
+		"untrimmed_bottom": "runtime.goexit",
+	}
+	if err := testhelp.ValidateResults(res, wanted); err != nil {
+		t.Error(err)
+	}
+}
+
+func BenchmarkLog(b *testing.B) {
+	format := func(level logf.LogLevel, msg string, props logf.PropsView) string {
+		return msg
+	}
+	for _, addCaller := range []bool{false, true} {
+		log, _ := logf.NewLogger(logf.Config{MaxLevel: logf.Debug, Format: format, Output: io.Discard, AddCaller: addCaller})
+		b.Run(fmt.Sprintf("add_caller=%t", addCaller), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				log.Log(logf.Informational, "test log", logf.String("a", "b"))
+			}
+		})
+	}
+}
+
+// failingWriter fails every write with err.
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestMultiLoggerErrors(t *testing.T) {
+	errA, errB := errors.New("a failed"), errors.New("b failed")
+	var out strings.Builder
+	newLogger := func(w io.Writer) logf.Logger {
+		log, _ := logf.NewLogger(logf.Config{MaxLevel: logf.Debug, Format: logf.Formatter(func(level logf.LogLevel, msg string, props logf.PropsView) string {
+			return msg
+		}), Output: w})
+		return log
+	}
+	multi := logf.NewMultiLogger(newLogger(failingWriter{errA}), newLogger(&out), newLogger(failingWriter{errB}))
+
+	err := multi.Log(logf.Error, "logged")
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("Log didn't join both errors: %v", err)
+	}
+	n, err := multi.Write([]byte("written"))
+	if !errors.Is(err, errA) || !errors.Is(err, errB) || n != len("written") {
+		t.Errorf("Write didn't join both errors: %d %v", n, err)
+	}
+	if out.String() != "logged\nwritten\n" {
+		t.Errorf("a failing sublogger stopped the others: %q", out.String())
+	}
+	if err := logf.NewMultiLogger(newLogger(&out)).Log(logf.Error, "ok"); err != nil {
+		t.Errorf("wanted no error, got %v", err)
+	}
+}
@@ -80,3 +80,18 @@ func KV(conf formats.KVConfig, max, def logf.LogLevel, output io.Writer) logf.Lo
 	})
 	return logger
 }
+
+// Console returns a logger that logs to output with max level max and default level def.
+// Uses the given ConsoleFormat settings. If conf.Output isn't set, output is checked for a terminal.
+func Console(conf formats.ConsoleConfig, max, def logf.LogLevel, output io.Writer) logf.Logger {
+	if conf.Output == nil {
+		conf.Output = output
+	}
+	logger, _ := logf.NewLogger(logf.Config{
+		MaxLevel:     max,
+		DefaultLevel: def,
+		Format:       formats.ConsoleFormat(conf),
+		Output:       output,
+	})
+	return logger
+}
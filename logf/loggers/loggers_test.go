@@ -43,6 +43,7 @@ var loggers = map[string]logf.Logger{
 	"json":        JSON(logf.Informational, logf.Informational, os.Stdout),
 	"json_pretty": JSONPretty(formats.JSONConfig{Indent: "  "}, logf.Informational, logf.Informational, os.Stdout),
 	"kv":          KV(formats.KVConfig{}, logf.Informational, logf.Informational, os.Stdout),
+	"console":     Console(formats.ConsoleConfig{}, logf.Informational, logf.Informational, os.Stdout),
 }
 
 // TODO: test loggers
@@ -15,46 +15,68 @@
 package output
 
 import (
-	"io"
+	"errors"
 	"os"
+	"sync"
 )
 
+var FileClosedError = errors.New("file output is closed")
+
+// File writes to a file from a background goroutine, so slow disks don't block the logger.
+// Writes are queued, up to buffer messages; Write blocks while the queue is full.
 type File struct {
-	writer io.Writer
-	queue  chan []byte
-	close  chan struct{}
+	file  *os.File
+	queue chan []byte
+	done  chan struct{}
+
+	// mu guards closed, and keeps Close from closing queue during a Write.
+	mu     sync.RWMutex
+	closed bool
 }
 
 func Open(path string, buffer uint8) (*File, error) {
-	f := &File{}
-	var err error
-	f.writer, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
 		return nil, err
 	}
-	f.queue = make(chan []byte, buffer)
-	f.close = make(chan struct{})
+	f := &File{
+		file:  file,
+		queue: make(chan []byte, buffer),
+		done:  make(chan struct{}),
+	}
 	go f.work()
 	return f, nil
 }
 
+// Write queues msg to be written. Writes after Close return FileClosedError.
 func (f *File) Write(msg []byte) (n int, err error) {
-	f.queue <- msg
-	return
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.closed {
+		return 0, FileClosedError
+	}
+	// Writers can't keep msg after returning, so the queue holds a copy.
+	f.queue <- append([]byte{}, msg...)
+	return len(msg), nil
 }
 
+// Close writes any queued messages, then closes the file.
 func (f *File) Close() {
-	f.close <- struct{}{}
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return
+	}
+	f.closed = true
+	close(f.queue)
+	f.mu.Unlock()
+	<-f.done
+	f.file.Close()
 }
 
 func (f *File) work() {
-	for {
-		select {
-		case msg := <-f.queue:
-			f.writer.Write(msg)
-		case <-f.close:
-			return
-		default:
-		}
+	defer close(f.done)
+	for msg := range f.queue {
+		f.file.Write(msg)
 	}
 }
@@ -15,6 +15,11 @@
 package output
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/decentplatforms/appkit/logf"
@@ -37,3 +42,28 @@ func TestFile(t *testing.T) {
 	}
 	log.Log(logf.Informational, "test log")
 }
+
+func TestFileClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "close.log")
+	writer, err := Open(path, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := fmt.Fprintf(writer, "line %d\n", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writer.Close()
+	writer.Close()
+	if _, err := writer.Write([]byte("late\n")); !errors.Is(err, FileClosedError) {
+		t.Errorf("write after close: got %v, want %v", err, FileClosedError)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 100 {
+		t.Errorf("got %d lines, want 100; queued writes were dropped on close", lines)
+	}
+}
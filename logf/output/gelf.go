@@ -0,0 +1,163 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+)
+
+var GELFTooLargeError = errors.New("GELF message needs more than 128 chunks")
+
+type GELFCompression int
+
+const (
+	GELFUncompressed = GELFCompression(iota)
+	GELFGzip
+	GELFZlib
+)
+
+// GELF chunked message header: 2 magic bytes, an 8 byte message ID, sequence number, and sequence count.
+const (
+	gelfChunkHeaderLen = 12
+	gelfMaxChunks      = 128
+)
+
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+// GELFUDPConfig configures GELFUDP.
+// Usage notes:
+//   - ChunkSize is the largest datagram sent, including the chunk header. It defaults to 1420,
+//     which fits in a typical ethernet MTU.
+//   - Compression defaults to GELFUncompressed.
+type GELFUDPConfig struct {
+	ChunkSize   int
+	Compression GELFCompression
+}
+
+func (conf GELFUDPConfig) withDefaults() GELFUDPConfig {
+	if conf.ChunkSize <= gelfChunkHeaderLen {
+		conf.ChunkSize = 1420
+	}
+	return conf
+}
+
+// GELFUDP sends GELF messages to a Graylog UDP input.
+// Use it with formats.GELFFormat. Messages larger than one datagram are chunked.
+type GELFUDP struct {
+	conn net.Conn
+	conf GELFUDPConfig
+}
+
+func DialGELFUDP(addr string, conf GELFUDPConfig) (*GELFUDP, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &GELFUDP{conn: conn, conf: conf.withDefaults()}, nil
+}
+
+func (g *GELFUDP) Write(msg []byte) (n int, err error) {
+	payload, err := g.compress(bytes.TrimRight(msg, "\n"))
+	if err != nil {
+		return 0, err
+	}
+	if len(payload) <= g.conf.ChunkSize {
+		if _, err := g.conn.Write(payload); err != nil {
+			return 0, err
+		}
+		return len(msg), nil
+	}
+
+	size := g.conf.ChunkSize - gelfChunkHeaderLen
+	count := (len(payload) + size - 1) / size
+	if count > gelfMaxChunks {
+		return 0, GELFTooLargeError
+	}
+	chunk := make([]byte, gelfChunkHeaderLen, g.conf.ChunkSize)
+	copy(chunk, gelfChunkMagic)
+	if _, err := rand.Read(chunk[2:10]); err != nil {
+		return 0, err
+	}
+	chunk[11] = byte(count)
+	for seq := 0; seq < count; seq++ {
+		start := seq * size
+		end := min(start+size, len(payload))
+		chunk[10] = byte(seq)
+		chunk = append(chunk[:gelfChunkHeaderLen], payload[start:end]...)
+		if _, err := g.conn.Write(chunk); err != nil {
+			return 0, err
+		}
+	}
+	return len(msg), nil
+}
+
+func (g *GELFUDP) compress(msg []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	switch g.conf.Compression {
+	case GELFGzip:
+		writer = gzip.NewWriter(&buf)
+	case GELFZlib:
+		writer = zlib.NewWriter(&buf)
+	default:
+		return msg, nil
+	}
+	if _, err := writer.Write(msg); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g *GELFUDP) Close() error {
+	return g.conn.Close()
+}
+
+// GELFTCP sends GELF messages to a Graylog TCP input.
+// Use it with formats.GELFFormat. Messages are framed with a null byte, and TCP inputs don't
+// support compression.
+type GELFTCP struct {
+	conn net.Conn
+}
+
+func DialGELFTCP(addr string) (*GELFTCP, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &GELFTCP{conn: conn}, nil
+}
+
+func (g *GELFTCP) Write(msg []byte) (n int, err error) {
+	trimmed := bytes.TrimRight(msg, "\n")
+	frame := make([]byte, len(trimmed)+1)
+	copy(frame, trimmed)
+	if _, err := g.conn.Write(frame); err != nil {
+		return 0, err
+	}
+	return len(msg), nil
+}
+
+func (g *GELFTCP) Close() error {
+	return g.conn.Close()
+}
@@ -0,0 +1,171 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+	"github.com/decentplatforms/appkit/logf/formats"
+)
+
+// readGELFUDP reads one GELF message from conn, reassembling chunks and decompressing.
+func readGELFUDP(t *testing.T, conn net.PacketConn) map[string]any {
+	t.Helper()
+	buf := make([]byte, 65536)
+	var payload []byte
+	chunks := map[byte][]byte{}
+	for {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		datagram := append([]byte{}, buf[:n]...)
+		if !bytes.HasPrefix(datagram, gelfChunkMagic) {
+			payload = datagram
+			break
+		}
+		chunks[datagram[10]] = datagram[gelfChunkHeaderLen:]
+		if count := int(datagram[11]); len(chunks) == count {
+			for seq := 0; seq < count; seq++ {
+				payload = append(payload, chunks[byte(seq)]...)
+			}
+			break
+		}
+	}
+	var reader io.Reader = bytes.NewReader(payload)
+	var err error
+	switch {
+	case bytes.HasPrefix(payload, []byte{0x1f, 0x8b}):
+		reader, err = gzip.NewReader(reader)
+	case payload[0] == 0x78:
+		reader, err = zlib.NewReader(reader)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := map[string]any{}
+	if err := json.NewDecoder(reader).Decode(&record); err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+func gelfLogger(t *testing.T, output io.Writer) logf.Logger {
+	t.Helper()
+	log, err := logf.NewLogger(logf.Config{
+		MaxLevel:     logf.Debug,
+		DefaultLevel: logf.Informational,
+		Format:       formats.GELFFormat(formats.GELFConfig{Host: "test-host"}),
+		Output:       output,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return log
+}
+
+var gelf_udp_configs = map[string]GELFUDPConfig{
+	"uncompressed": {},
+	"chunked":      {ChunkSize: 100},
+	"gzip":         {Compression: GELFGzip},
+	"gzip.chunked": {Compression: GELFGzip, ChunkSize: 64},
+	"zlib":         {Compression: GELFZlib},
+	"zlib.chunked": {Compression: GELFZlib, ChunkSize: 64},
+}
+
+func TestGELFUDP(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	full := "first line\n" + strings.Repeat("detail ", 200) + "end"
+	for name, conf := range gelf_udp_configs {
+		t.Run(name, func(t *testing.T) {
+			writer, err := DialGELFUDP(listener.LocalAddr().String(), conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer writer.Close()
+			err = gelfLogger(t, writer).Log(logf.Error, full, logf.String("id", "abc"), logf.Int("status", 500))
+			if err != nil {
+				t.Fatal(err)
+			}
+			record := readGELFUDP(t, listener)
+			wanted := map[string]any{
+				"version":       "1.1",
+				"host":          "test-host",
+				"short_message": "first line",
+				"full_message":  full,
+				"level":         float64(3),
+				"__id":          "abc",
+				"_status":       float64(500),
+			}
+			for k, v := range wanted {
+				if record[k] != v {
+					t.Errorf("wanted %s=%v, got %v", k, v, record[k])
+				}
+			}
+		})
+	}
+}
+
+func TestGELFTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	writer, err := DialGELFTCP(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	log := gelfLogger(t, writer)
+	log.Log(logf.Informational, "first")
+	log.Log(logf.Warning, "second")
+	reader := bufio.NewReader(conn)
+	for _, wanted := range []string{"first", "second"} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		frame, err := reader.ReadBytes(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		record := map[string]any{}
+		if err := json.Unmarshal(frame[:len(frame)-1], &record); err != nil {
+			t.Fatal(err)
+		}
+		if record["short_message"] != wanted {
+			t.Errorf("wanted short_message %q, got %v", wanted, record["short_message"])
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// JournalSocket is the systemd journal's native protocol socket.
+const JournalSocket = "/run/systemd/journal/socket"
+
+// Journal writes records to the systemd journal using its native protocol.
+// Use it with formats.JournaldFormat; each Write is sent as one journal entry.
+//
+// Entries too large for a datagram are written to a sealed memfd, and the file descriptor
+// is sent instead, as the protocol describes.
+type Journal struct {
+	conn *net.UnixConn
+}
+
+// OpenJournal connects to the journal socket at path, or JournalSocket if path is "".
+func OpenJournal(path string) (*Journal, error) {
+	if path == "" {
+		path = JournalSocket
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{conn: conn}, nil
+}
+
+func (j *Journal) Write(msg []byte) (n int, err error) {
+	n, err = j.conn.Write(msg)
+	if errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS) {
+		return sendMemfd(j.conn, msg)
+	}
+	return n, err
+}
+
+func (j *Journal) Close() error {
+	return j.conn.Close()
+}
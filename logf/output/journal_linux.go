@@ -0,0 +1,97 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package output
+
+import (
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// memfdCreate is the memfd_create syscall number by architecture; package syscall doesn't define it.
+var memfdCreate = map[string]uintptr{
+	"386":      356,
+	"amd64":    319,
+	"arm":      385,
+	"arm64":    279,
+	"loong64":  279,
+	"mips":     4354,
+	"mipsle":   4354,
+	"mips64":   5314,
+	"mips64le": 5314,
+	"ppc64":    360,
+	"ppc64le":  360,
+	"riscv64":  279,
+	"s390x":    350,
+}
+
+// memfd and sealing constants from linux/memfd.h and linux/fcntl.h.
+const (
+	mfdCloexec       = 0x1
+	mfdAllowSealing  = 0x2
+	fAddSeals        = 1033
+	fSealSeal        = 0x1
+	fSealShrink      = 0x2
+	fSealGrow        = 0x4
+	fSealWrite       = 0x8
+	journalMemfdName = "logf-journal"
+)
+
+// sendMemfd writes msg to a sealed memfd and sends its file descriptor over conn.
+// journald requires memfds to be sealed so the contents can't change after sending.
+func sendMemfd(conn *net.UnixConn, msg []byte) (int, error) {
+	trap, ok := memfdCreate[runtime.GOARCH]
+	if !ok {
+		return 0, syscall.EMSGSIZE
+	}
+	name, err := syscall.BytePtrFromString(journalMemfdName)
+	if err != nil {
+		return 0, err
+	}
+	fd, _, errno := syscall.Syscall(trap, uintptr(unsafe.Pointer(name)), mfdCloexec|mfdAllowSealing, 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	file := os.NewFile(fd, journalMemfdName)
+	defer file.Close()
+	if _, err := file.Write(msg); err != nil {
+		return 0, err
+	}
+	_, _, errno = syscall.Syscall(syscall.SYS_FCNTL, fd, fAddSeals, fSealSeal|fSealShrink|fSealGrow|fSealWrite)
+	if errno != 0 {
+		return 0, errno
+	}
+	// net refuses WriteMsgUnix on connected datagram sockets, so send on the raw socket.
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var sendErr error
+	err = raw.Write(func(sock uintptr) bool {
+		sendErr = syscall.Sendmsg(int(sock), nil, syscall.UnixRights(int(fd)), nil, 0)
+		return sendErr != syscall.EAGAIN
+	})
+	if err == nil {
+		err = sendErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(msg), nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package output
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+	"github.com/decentplatforms/appkit/logf/formats"
+)
+
+// readJournalEntry reads one entry from a stand-in journal socket, following memfd handoffs.
+func readJournalEntry(t *testing.T, conn *net.UnixConn) map[string]string {
+	t.Helper()
+	buf := make([]byte, 64*1024)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := buf[:n]
+	if oobn > 0 {
+		msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+		if err != nil {
+			t.Fatal(err)
+		}
+		fds, err := syscall.ParseUnixRights(&msgs[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		file := os.NewFile(uintptr(fds[0]), "memfd")
+		defer file.Close()
+		stat, err := file.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry, err = io.ReadAll(io.NewSectionReader(file, 0, stat.Size()))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return parseJournalEntry(t, entry)
+}
+
+func parseJournalEntry(t *testing.T, entry []byte) map[string]string {
+	t.Helper()
+	fields := make(map[string]string)
+	for len(entry) > 0 {
+		end := bytes.IndexByte(entry, '\n')
+		if end < 0 {
+			t.Fatalf("unterminated field %q", entry)
+		}
+		if eq := bytes.IndexByte(entry[:end], '='); eq >= 0 {
+			fields[string(entry[:eq])] = string(entry[eq+1 : end])
+			entry = entry[end+1:]
+			continue
+		}
+		name := string(entry[:end])
+		size := binary.LittleEndian.Uint64(entry[end+1 : end+9])
+		fields[name] = string(entry[end+9 : end+9+int(size)])
+		entry = entry[end+9+int(size)+1:]
+	}
+	return fields
+}
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	listener, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	writer, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	log, err := logf.NewLogger(logf.Config{
+		MaxLevel:     logf.Debug,
+		DefaultLevel: logf.Informational,
+		Format:       formats.JournaldFormat(formats.JournaldConfig{Identifier: "journal-test"}),
+		Output:       writer,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("datagram", func(t *testing.T) {
+		err := log.Log(logf.Warning, "test log", logf.String("request-id", "abc"), logf.String("detail", "two\nlines"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields := readJournalEntry(t, listener)
+		wanted := map[string]string{
+			"MESSAGE":           "test log",
+			"PRIORITY":          "4",
+			"SYSLOG_IDENTIFIER": "journal-test",
+			"REQUEST_ID":        "abc",
+			"DETAIL":            "two\nlines",
+		}
+		for k, v := range wanted {
+			if fields[k] != v {
+				t.Errorf("wanted %s=%q, got %q", k, v, fields[k])
+			}
+		}
+	})
+	t.Run("memfd", func(t *testing.T) {
+		msg := strings.Repeat("x", 1024*1024)
+		if err := log.Log(logf.Informational, msg); err != nil {
+			t.Fatal(err)
+		}
+		fields := readJournalEntry(t, listener)
+		if fields["MESSAGE"] != msg || fields["PRIORITY"] != "6" {
+			t.Errorf("large entry didn't round trip: got %d byte message, priority %q", len(fields["MESSAGE"]), fields["PRIORITY"])
+		}
+	})
+}
@@ -0,0 +1,259 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var NilEndpointError = errors.New("OTLP exporter must have an endpoint")
+var ExporterClosedError = errors.New("OTLP exporter is shut down")
+var InvalidRecordError = errors.New("OTLP record is not valid JSON")
+var ExporterFullError = errors.New("OTLP exporter buffer is full")
+
+// OTLPConfig configures OTLP.
+// Usage notes:
+//   - Endpoint is the full URL of the logs endpoint, like http://localhost:4318/v1/logs
+//   - Client defaults to an http.Client with a 10 second timeout
+//   - BatchSize defaults to 512 records; a full batch is exported immediately
+//   - FlushInterval defaults to 5 seconds
+//   - MaxRetries defaults to 5. Only 429 and 503 responses are retried.
+//   - RetryBackoff is the first retry delay, and defaults to 1 second. It doubles on each retry,
+//     unless the response has a Retry-After header.
+//   - OnError is called with background export errors. Errors are dropped if it's nil.
+//   - MaxBuffered caps the records waiting to be exported, and defaults to 8 batches. While exports
+//     are failing or slow, Writes past the cap drop the record and return ExporterFullError.
+type OTLPConfig struct {
+	Endpoint      string
+	Headers       map[string]string
+	Client        *http.Client
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxRetries    int
+	RetryBackoff  time.Duration
+	OnError       func(error)
+	MaxBuffered   int
+}
+
+func (conf OTLPConfig) withDefaults() OTLPConfig {
+	if conf.Client == nil {
+		conf.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = 512
+	}
+	if conf.FlushInterval <= 0 {
+		conf.FlushInterval = 5 * time.Second
+	}
+	if conf.MaxRetries <= 0 {
+		conf.MaxRetries = 5
+	}
+	if conf.RetryBackoff <= 0 {
+		conf.RetryBackoff = time.Second
+	}
+	if conf.MaxBuffered <= 0 {
+		conf.MaxBuffered = 8 * conf.BatchSize
+	}
+	return conf
+}
+
+// OTLP batches records and exports them as OTLP/HTTP JSON ExportLogsServiceRequests.
+// Use it with formats.OTelFormat; each Write is one ResourceLogs object.
+//
+// Call Shutdown to export any buffered records before exiting.
+type OTLP struct {
+	conf OTLPConfig
+
+	mu     sync.Mutex
+	batch  []json.RawMessage
+	closed bool
+
+	// exportMu keeps batches in order.
+	exportMu sync.Mutex
+
+	flush chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+	// cancel aborts the worker's export when Shutdown's context ends first.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewOTLP(conf OTLPConfig) (*OTLP, error) {
+	if conf.Endpoint == "" {
+		return nil, NilEndpointError
+	}
+	exp := &OTLP{
+		conf:  conf.withDefaults(),
+		flush: make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	exp.ctx, exp.cancel = context.WithCancel(context.Background())
+	go exp.work()
+	return exp, nil
+}
+
+func (exp *OTLP) Write(msg []byte) (n int, err error) {
+	record := bytes.TrimSpace(msg)
+	if !json.Valid(record) {
+		return 0, InvalidRecordError
+	}
+	exp.mu.Lock()
+	defer exp.mu.Unlock()
+	if exp.closed {
+		return 0, ExporterClosedError
+	}
+	if len(exp.batch) >= exp.conf.MaxBuffered {
+		return 0, ExporterFullError
+	}
+	exp.batch = append(exp.batch, append(json.RawMessage{}, record...))
+	if len(exp.batch) >= exp.conf.BatchSize {
+		select {
+		case exp.flush <- struct{}{}:
+		default:
+		}
+	}
+	return len(msg), nil
+}
+
+// Flush exports buffered records now, in requests of up to BatchSize records.
+func (exp *OTLP) Flush(ctx context.Context) error {
+	exp.exportMu.Lock()
+	defer exp.exportMu.Unlock()
+	exp.mu.Lock()
+	pending := exp.batch
+	exp.batch = nil
+	exp.mu.Unlock()
+	var err error
+	for len(pending) > 0 {
+		batch := pending[:min(len(pending), exp.conf.BatchSize)]
+		pending = pending[len(batch):]
+		err = errors.Join(err, exp.export(ctx, batch))
+	}
+	return err
+}
+
+// Shutdown stops the exporter and exports any buffered records.
+// If ctx ends first, the export in progress is canceled and the remaining records are dropped.
+// Writes after Shutdown return ExporterClosedError.
+func (exp *OTLP) Shutdown(ctx context.Context) error {
+	exp.mu.Lock()
+	if exp.closed {
+		exp.mu.Unlock()
+		return ExporterClosedError
+	}
+	exp.closed = true
+	exp.mu.Unlock()
+	defer exp.cancel()
+	close(exp.stop)
+	var err error
+	select {
+	case <-exp.done:
+	case <-ctx.Done():
+		exp.cancel()
+		<-exp.done
+		err = ctx.Err()
+	}
+	return errors.Join(err, exp.Flush(ctx))
+}
+
+func (exp *OTLP) work() {
+	defer close(exp.done)
+	ticker := time.NewTicker(exp.conf.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-exp.flush:
+		case <-exp.stop:
+			return
+		}
+		if err := exp.Flush(exp.ctx); err != nil && exp.conf.OnError != nil {
+			exp.conf.OnError(err)
+		}
+	}
+}
+
+func (exp *OTLP) export(ctx context.Context, batch []json.RawMessage) error {
+	var body bytes.Buffer
+	body.WriteString(`{"resourceLogs":[`)
+	for i, record := range batch {
+		if i > 0 {
+			body.WriteByte(',')
+		}
+		body.Write(record)
+	}
+	body.WriteString(`]}`)
+
+	backoff := exp.conf.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := exp.post(ctx, body.Bytes())
+		if err == nil {
+			return nil
+		}
+		if retryAfter < 0 || attempt >= exp.conf.MaxRetries {
+			return err
+		}
+		if retryAfter == 0 {
+			retryAfter = backoff
+			backoff *= 2
+		}
+		select {
+		case <-time.After(retryAfter):
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		}
+	}
+}
+
+// post sends one request. If it fails with a retryable status, it returns the delay the server
+// asked for, or 0 to use the default backoff; otherwise the delay is negative.
+func (exp *OTLP) post(ctx context.Context, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, exp.conf.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range exp.conf.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := exp.conf.Client.Do(req)
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return 0, nil
+	}
+	err = fmt.Errorf("OTLP export failed: %s", resp.Status)
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return -1, err
+	}
+	if secs, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil && secs > 0 {
+		return time.Duration(secs) * time.Second, err
+	}
+	return 0, err
+}
@@ -0,0 +1,176 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+	"github.com/decentplatforms/appkit/logf/formats"
+)
+
+type otlpRequest struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []struct {
+				Key   string
+				Value map[string]any
+			}
+		}
+		ScopeLogs []struct {
+			LogRecords []struct {
+				SeverityNumber int
+				SeverityText   string
+				Body           map[string]any
+				Attributes     []struct {
+					Key   string
+					Value map[string]any
+				}
+				TraceID string
+			}
+		}
+	}
+}
+
+// otlpCollector is a stand-in OTLP/HTTP endpoint. It rejects the first request with 503.
+type otlpCollector struct {
+	mu       sync.Mutex
+	attempts int
+	requests []otlpRequest
+}
+
+func (c *otlpCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attempts++
+	if c.attempts == 1 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	req := otlpRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.requests = append(c.requests, req)
+}
+
+func TestOTLP(t *testing.T) {
+	collector := &otlpCollector{}
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+
+	exp, err := NewOTLP(OTLPConfig{
+		Endpoint:      srv.URL + "/v1/logs",
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		RetryBackoff:  time.Millisecond,
+		OnError:       func(err error) { t.Error(err) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log, err := logf.NewLogger(logf.Config{
+		MaxLevel:     logf.Debug,
+		DefaultLevel: logf.Informational,
+		Format:       formats.OTelFormat(formats.OTelConfig{ServiceName: "otlp-test"}),
+		Output:       exp,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Log(logf.Error, "first", logf.Int("status", 500), logf.String(formats.OTEL_TRACE_ID, "0af7651916cd43dd8448eb211c80319c"))
+	log.Log(logf.Informational, "second")
+	log.Log(logf.Debug, "third")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := exp.Write([]byte("{}")); err != ExporterClosedError {
+		t.Errorf("wanted ExporterClosedError, got %v", err)
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if collector.attempts != 3 || len(collector.requests) != 2 {
+		t.Fatalf("wanted 1 retry and 2 batches, got %d attempts and %d batches", collector.attempts, len(collector.requests))
+	}
+	if ct := len(collector.requests[0].ResourceLogs); ct != 2 {
+		t.Errorf("wanted first batch of 2, got %d", ct)
+	}
+	first := collector.requests[0].ResourceLogs[0]
+	if attr := first.Resource.Attributes[0]; attr.Key != "service.name" || attr.Value["stringValue"] != "otlp-test" {
+		t.Errorf("wanted service.name resource attribute, got %+v", attr)
+	}
+	record := first.ScopeLogs[0].LogRecords[0]
+	if record.SeverityNumber != 17 || record.SeverityText != "ERROR" || record.Body["stringValue"] != "first" {
+		t.Errorf("wrong record: %+v", record)
+	}
+	if record.TraceID != "0af7651916cd43dd8448eb211c80319c" {
+		t.Errorf("wanted traceId from prop, got %q", record.TraceID)
+	}
+	if len(record.Attributes) != 1 || record.Attributes[0].Key != "status" || record.Attributes[0].Value["intValue"] != "500" {
+		t.Errorf("wanted status attribute, got %+v", record.Attributes)
+	}
+	if last := collector.requests[1].ResourceLogs[0].ScopeLogs[0].LogRecords[0]; last.Body["stringValue"] != "third" {
+		t.Errorf("wanted shutdown to flush the last record, got %+v", last)
+	}
+}
+
+func TestOTLPShutdownDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	exp, err := NewOTLP(OTLPConfig{Endpoint: srv.URL, BatchSize: 1, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first record starts a background export that waits 60s to retry.
+	exp.Write([]byte("{}"))
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := exp.Shutdown(ctx); err == nil {
+		t.Error("wanted an error from a Shutdown that ran out of time")
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("wanted Shutdown to stop at its deadline, took %v", took)
+	}
+}
+
+func TestOTLPBufferCap(t *testing.T) {
+	exp, err := NewOTLP(OTLPConfig{Endpoint: "http://127.0.0.1:0", BatchSize: 10, FlushInterval: time.Hour, MaxBuffered: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exp.Shutdown(context.Background())
+	for i, wanted := range []error{nil, nil, ExporterFullError} {
+		if _, err := exp.Write([]byte("{}")); err != wanted {
+			t.Errorf("write %d: wanted %v, got %v", i, wanted, err)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+	"github.com/decentplatforms/appkit/logf/formats"
+)
+
+var MissingPriorityError = errors.New("syslog message has no valid PRI")
+var MalformedHeaderError = errors.New("syslog message has a malformed RFC 5424 header")
+var MalformedStructuredDataError = errors.New("syslog message has malformed RFC 5424 structured data")
+
+const nilvalue = "-"
+
+// Message is a parsed syslog message.
+// Header fields that were missing or NILVALUE in the original message are empty.
+type Message struct {
+	Level     logf.LogLevel
+	Facility  int
+	Timestamp time.Time
+	Hostname  string
+	AppName   string
+	ProcID    string
+	// Tag is the RFC 3164 TAG or the RFC 5424 MSGID.
+	Tag     string
+	Message string
+	// Structured holds RFC 5424 SD-PARAMs in the order they appeared, named by PARAM-NAME.
+	Structured []logf.Prop
+}
+
+// Props returns the props that reproduce msg through a logf.Logger.
+// Header fields map onto the formats.SYSLOG_X props, so re-emitting through a syslog format
+// keeps the original timestamp, facility, hostname, app name, process ID, and tag.
+func (msg Message) Props() []logf.Prop {
+	props := make([]logf.Prop, 0, 6+len(msg.Structured))
+	if !msg.Timestamp.IsZero() {
+		props = append(props, logf.Time(formats.SYSLOG_TIMESTAMP, msg.Timestamp))
+	}
+	props = append(props, logf.Int(formats.SYSLOG_FACILITY, msg.Facility))
+	if msg.Hostname != "" {
+		props = append(props, logf.String(formats.SYSLOG_HOSTNAME, msg.Hostname))
+	}
+	if msg.AppName != "" {
+		props = append(props, logf.String(formats.SYSLOG_APPNAME, msg.AppName))
+	}
+	if msg.ProcID != "" {
+		props = append(props, logf.String(formats.SYSLOG_PROCID, msg.ProcID))
+	}
+	if msg.Tag != "" {
+		props = append(props, logf.String(formats.SYSLOG_TAG, msg.Tag))
+	}
+	return append(props, msg.Structured...)
+}
+
+// Parse parses a single syslog message in either RFC 5424 or RFC 3164 format.
+// The format is detected from the VERSION field following PRI.
+//
+// RFC 3164 parsing is lenient, as the RFC asks of relays: if the header can't be parsed,
+// everything after PRI is treated as the message. Only a missing PRI is an error.
+func Parse(raw []byte) (Message, error) {
+	line := strings.TrimRight(string(raw), "\r\n\x00")
+	msg := Message{}
+	pri, rest, err := parsePriority(line)
+	if err != nil {
+		return msg, err
+	}
+	msg.Facility = pri / 8
+	msg.Level = logf.LogLevel(pri % 8)
+	if strings.HasPrefix(rest, "1 ") {
+		return parse5424(msg, rest[2:])
+	}
+	return parse3164(msg, rest), nil
+}
+
+func parsePriority(line string) (int, string, error) {
+	if !strings.HasPrefix(line, "<") {
+		return 0, "", MissingPriorityError
+	}
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return 0, "", MissingPriorityError
+	}
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, "", MissingPriorityError
+	}
+	return pri, line[end+1:], nil
+}
+
+func parse5424(msg Message, rest string) (Message, error) {
+	fields := make([]string, 5)
+	for i := range fields {
+		sp := strings.IndexByte(rest, ' ')
+		if sp <= 0 {
+			return msg, MalformedHeaderError
+		}
+		fields[i], rest = rest[:sp], rest[sp+1:]
+	}
+	if fields[0] != nilvalue {
+		ts, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return msg, MalformedHeaderError
+		}
+		msg.Timestamp = ts
+	}
+	msg.Hostname = nilToEmpty(fields[1])
+	msg.AppName = nilToEmpty(fields[2])
+	msg.ProcID = nilToEmpty(fields[3])
+	msg.Tag = nilToEmpty(fields[4])
+
+	structured, rest, err := parseStructuredData(rest)
+	if err != nil {
+		return msg, err
+	}
+	msg.Structured = structured
+	rest = strings.TrimPrefix(rest, " ")
+	msg.Message = strings.TrimPrefix(rest, "\ufeff")
+	return msg, nil
+}
+
+// parseStructuredData parses STRUCTURED-DATA at the start of rest, and returns the
+// remainder of the message.
+func parseStructuredData(rest string) ([]logf.Prop, string, error) {
+	if rest == nilvalue || strings.HasPrefix(rest, nilvalue+" ") {
+		return nil, rest[1:], nil
+	}
+	if !strings.HasPrefix(rest, "[") {
+		return nil, "", MalformedStructuredDataError
+	}
+	props := []logf.Prop{}
+	for strings.HasPrefix(rest, "[") {
+		end := strings.IndexAny(rest, " ]")
+		if end < 0 {
+			return nil, "", MalformedStructuredDataError
+		}
+		rest = rest[end:]
+		for strings.HasPrefix(rest, " ") {
+			rest = rest[1:]
+			eq := strings.Index(rest, "=\"")
+			if eq <= 0 {
+				return nil, "", MalformedStructuredDataError
+			}
+			name := rest[:eq]
+			value, n, ok := parseParamValue(rest[eq+2:])
+			if !ok {
+				return nil, "", MalformedStructuredDataError
+			}
+			props = append(props, logf.String(name, value))
+			rest = rest[eq+2+n:]
+		}
+		if !strings.HasPrefix(rest, "]") {
+			return nil, "", MalformedStructuredDataError
+		}
+		rest = rest[1:]
+	}
+	return props, rest, nil
+}
+
+// parseParamValue reads an escaped PARAM-VALUE up to its closing quote.
+// It returns the unescaped value and the number of bytes consumed, including the closing quote.
+func parseParamValue(rest string) (string, int, bool) {
+	var value strings.Builder
+	for i := 0; i < len(rest); i++ {
+		switch rest[i] {
+		case '\\':
+			if i+1 < len(rest) && strings.IndexByte(`"\]`, rest[i+1]) >= 0 {
+				i++
+			}
+			value.WriteByte(rest[i])
+		case '"':
+			return value.String(), i + 1, true
+		default:
+			value.WriteByte(rest[i])
+		}
+	}
+	return "", 0, false
+}
+
+// RFC 3164 timestamps: the traditional Mmm dd hh:mm:ss, or RFC 3339 as written by
+// formats.Syslog3164Format when UseISO8601 is set.
+const stampLen = len(time.Stamp)
+
+func parse3164(msg Message, rest string) Message {
+	var ts time.Time
+	var err error
+	if len(rest) > stampLen && rest[stampLen] == ' ' {
+		ts, err = time.Parse(time.Stamp, rest[:stampLen])
+		if err == nil {
+			rest = rest[stampLen+1:]
+		}
+	}
+	if ts.IsZero() {
+		if sp := strings.IndexByte(rest, ' '); sp > 0 {
+			ts, err = time.Parse(time.RFC3339Nano, rest[:sp])
+			if err == nil {
+				rest = rest[sp+1:]
+			}
+		}
+	}
+	if ts.IsZero() {
+		msg.Message = rest
+		return msg
+	}
+	if ts.Year() == 0 {
+		ts = ts.AddDate(time.Now().UTC().Year(), 0, 0)
+	}
+	msg.Timestamp = ts
+
+	if sp := strings.IndexByte(rest, ' '); sp > 0 {
+		msg.Hostname, rest = rest[:sp], rest[sp+1:]
+	}
+	// TAG is alphanumeric and ends at the first non-alphanumeric character, usually
+	// ':' or '['. Anything that doesn't look like a tag is left in the message.
+	end := strings.IndexAny(rest, ":[ ")
+	if end <= 0 {
+		msg.Message = rest
+		return msg
+	}
+	tag, after := rest[:end], rest[end:]
+	if strings.HasPrefix(after, "[") {
+		close := strings.Index(after, "]")
+		if close < 0 {
+			msg.Message = rest
+			return msg
+		}
+		msg.ProcID, after = after[1:close], after[close+1:]
+	}
+	if !strings.HasPrefix(after, ":") {
+		msg.ProcID = ""
+		msg.Message = rest
+		return msg
+	}
+	msg.Tag = tag
+	msg.Message = strings.TrimPrefix(after[1:], " ")
+	return msg
+}
+
+func nilToEmpty(field string) string {
+	if field == nilvalue {
+		return ""
+	}
+	return field
+}
+
+// splitOctetCounted reports whether data begins with an RFC 6587 octet-counted frame
+// ("MSG-LEN SP SYSLOG-MSG"), and if so, the length of the header and the message.
+func splitOctetCounted(data []byte) (header, length int, ok bool) {
+	sp := bytes.IndexByte(data, ' ')
+	if sp <= 0 || sp > 10 {
+		return 0, 0, false
+	}
+	length, err := strconv.Atoi(string(data[:sp]))
+	if err != nil || length <= 0 {
+		return 0, 0, false
+	}
+	return sp + 1, length, true
+}
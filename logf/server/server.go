@@ -0,0 +1,408 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package server is a small syslog receiver and relay for environments without a system syslog daemon.
+// It accepts RFC 3164 and RFC 5424 messages over UDP, TCP, and Unix sockets, and re-emits them through
+// a logf.Logger, so the receiving side may use any format and output logf supports.
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+)
+
+var NilLoggerError = errors.New("server must have a logger")
+var ServerClosedError = errors.New("server is closed")
+var MessageTooLargeError = errors.New("syslog message exceeds MaxMessageSize")
+
+// Config configures a Server.
+// Usage notes:
+//   - MaxMessageSize defaults to 64KiB. Larger messages are dropped; on stream connections, the connection is closed.
+//   - MaxConnections limits concurrent stream connections across all listeners. 0 means no limit.
+//   - IdleTimeout closes stream connections that haven't sent a message in that long. 0 means no timeout.
+//   - KeepUnparsed writes messages that fail to parse through Logger.Write instead of dropping them.
+type Config struct {
+	Logger         logf.Logger
+	MaxMessageSize int
+	MaxConnections int
+	IdleTimeout    time.Duration
+	KeepUnparsed   bool
+}
+
+func (conf Config) withDefaults() Config {
+	if conf.MaxMessageSize <= 0 {
+		conf.MaxMessageSize = 64 * 1024
+	}
+	return conf
+}
+
+// Metrics are counters for a Server's lifetime.
+type Metrics struct {
+	// Received counts messages read from any listener, including ones that failed to parse.
+	Received uint64
+	// ParseErrors counts messages that couldn't be parsed.
+	ParseErrors uint64
+	// Oversized counts messages dropped for exceeding MaxMessageSize.
+	Oversized uint64
+	// LogErrors counts messages the Logger failed to write.
+	LogErrors uint64
+	// Connections is the current number of open stream connections.
+	Connections int64
+	// Rejected counts stream connections refused because of MaxConnections.
+	Rejected uint64
+}
+
+type metrics struct {
+	received    atomic.Uint64
+	parseErrors atomic.Uint64
+	oversized   atomic.Uint64
+	logErrors   atomic.Uint64
+	connections atomic.Int64
+	rejected    atomic.Uint64
+}
+
+// Server receives syslog messages and re-emits them through a logf.Logger.
+// Listen on as many sockets as needed, then call Shutdown to stop.
+type Server struct {
+	conf    Config
+	metrics metrics
+
+	mu        sync.Mutex
+	closed    bool
+	listeners []io.Closer
+	conns     map[net.Conn]struct{}
+	sockets   []string
+
+	// logMu serializes writes, since loggers don't guarantee safe concurrent use.
+	logMu sync.Mutex
+
+	wg sync.WaitGroup
+}
+
+func New(conf Config) (*Server, error) {
+	if conf.Logger == nil {
+		return nil, NilLoggerError
+	}
+	return &Server{
+		conf:  conf.withDefaults(),
+		conns: make(map[net.Conn]struct{}),
+	}, nil
+}
+
+// Metrics returns a snapshot of the server's counters.
+func (srv *Server) Metrics() Metrics {
+	return Metrics{
+		Received:    srv.metrics.received.Load(),
+		ParseErrors: srv.metrics.parseErrors.Load(),
+		Oversized:   srv.metrics.oversized.Load(),
+		LogErrors:   srv.metrics.logErrors.Load(),
+		Connections: srv.metrics.connections.Load(),
+		Rejected:    srv.metrics.rejected.Load(),
+	}
+}
+
+// ListenUDP receives datagrams on a UDP address, one message per datagram.
+func (srv *Server) ListenUDP(addr string) (net.Addr, error) {
+	return srv.ListenPacket("udp", addr)
+}
+
+// ListenTCP accepts stream connections on a TCP address.
+// Both RFC 6587 framings are accepted: newline-terminated, and octet-counted.
+func (srv *Server) ListenTCP(addr string) (net.Addr, error) {
+	return srv.ListenStream("tcp", addr)
+}
+
+// ListenUnix receives datagrams on a Unix socket, like /dev/log.
+// The socket file is removed on Shutdown.
+func (srv *Server) ListenUnix(path string) (net.Addr, error) {
+	return srv.ListenPacket("unixgram", path)
+}
+
+// ListenUnixStream accepts stream connections on a Unix socket.
+// The socket file is removed on Shutdown.
+func (srv *Server) ListenUnixStream(path string) (net.Addr, error) {
+	return srv.ListenStream("unix", path)
+}
+
+// ListenPacket receives datagrams using any packet-oriented network supported by net.ListenPacket.
+func (srv *Server) ListenPacket(network, addr string) (net.Addr, error) {
+	conn, err := net.ListenPacket(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := srv.track(conn, network, addr); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	srv.wg.Add(1)
+	go srv.servePacket(conn)
+	return conn.LocalAddr(), nil
+}
+
+// ListenStream accepts connections using any stream-oriented network supported by net.Listen.
+func (srv *Server) ListenStream(network, addr string) (net.Addr, error) {
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := srv.track(listener, network, addr); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	srv.wg.Add(1)
+	go srv.serveStream(listener)
+	return listener.Addr(), nil
+}
+
+func (srv *Server) track(listener io.Closer, network, addr string) error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.closed {
+		return ServerClosedError
+	}
+	srv.listeners = append(srv.listeners, listener)
+	if network == "unix" || network == "unixgram" {
+		srv.sockets = append(srv.sockets, addr)
+	}
+	return nil
+}
+
+// Shutdown stops all listeners, then waits for open connections to finish their current messages.
+// If ctx expires first, remaining connections are closed and ctx.Err() is returned.
+func (srv *Server) Shutdown(ctx context.Context) error {
+	srv.mu.Lock()
+	if srv.closed {
+		srv.mu.Unlock()
+		return ServerClosedError
+	}
+	srv.closed = true
+	for _, listener := range srv.listeners {
+		listener.Close()
+	}
+	// Unblock reads so connections stop after the message in progress.
+	for conn := range srv.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	srv.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		srv.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		srv.mu.Lock()
+		for conn := range srv.conns {
+			conn.Close()
+		}
+		srv.mu.Unlock()
+		<-done
+		err = ctx.Err()
+	}
+	for _, path := range srv.sockets {
+		os.Remove(path)
+	}
+	return err
+}
+
+func (srv *Server) servePacket(conn net.PacketConn) {
+	defer srv.wg.Done()
+	// One extra byte detects datagrams that were truncated to fit the buffer.
+	buf := make([]byte, srv.conf.MaxMessageSize+1)
+	var delay time.Duration
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if srv.isClosed() {
+				return
+			}
+			delay = retryDelay(delay)
+			time.Sleep(delay)
+			continue
+		}
+		delay = 0
+		if n > srv.conf.MaxMessageSize {
+			srv.metrics.received.Add(1)
+			srv.metrics.oversized.Add(1)
+			continue
+		}
+		srv.handle(buf[:n])
+	}
+}
+
+func (srv *Server) serveStream(listener net.Listener) {
+	defer srv.wg.Done()
+	var delay time.Duration
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if srv.isClosed() {
+				return
+			}
+			delay = retryDelay(delay)
+			time.Sleep(delay)
+			continue
+		}
+		delay = 0
+		if !srv.open(conn) {
+			conn.Close()
+			continue
+		}
+		srv.wg.Add(1)
+		go srv.serveConn(conn)
+	}
+}
+
+// retryDelay returns how long to wait after another failed read or accept, so a lasting error
+// like EMFILE doesn't spin. Like net/http's accept loop, it starts at 5ms and doubles up to 1s.
+func retryDelay(last time.Duration) time.Duration {
+	if last == 0 {
+		return 5 * time.Millisecond
+	}
+	return min(2*last, time.Second)
+}
+
+func (srv *Server) open(conn net.Conn) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.closed {
+		return false
+	}
+	if max := srv.conf.MaxConnections; max > 0 && len(srv.conns) >= max {
+		srv.metrics.rejected.Add(1)
+		return false
+	}
+	srv.conns[conn] = struct{}{}
+	srv.metrics.connections.Add(1)
+	return true
+}
+
+func (srv *Server) release(conn net.Conn) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	delete(srv.conns, conn)
+	srv.metrics.connections.Add(-1)
+	conn.Close()
+}
+
+func (srv *Server) serveConn(conn net.Conn) {
+	defer srv.wg.Done()
+	defer srv.release(conn)
+	reader := bufio.NewReaderSize(conn, 4096)
+	for {
+		if !srv.extendDeadline(conn) && reader.Buffered() == 0 {
+			return
+		}
+		frame, err := srv.readFrame(reader)
+		if len(frame) > 0 {
+			srv.handle(frame)
+		}
+		if err != nil {
+			if errors.Is(err, MessageTooLargeError) {
+				srv.metrics.received.Add(1)
+				srv.metrics.oversized.Add(1)
+			}
+			return
+		}
+	}
+}
+
+// readFrame reads one message from a stream.
+// Octet-counted frames start with a digit, since a syslog message always starts with '<';
+// anything else is read up to the next newline.
+func (srv *Server) readFrame(reader *bufio.Reader) ([]byte, error) {
+	peek, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if peek[0] >= '0' && peek[0] <= '9' {
+		header, _ := reader.Peek(11)
+		if hlen, length, ok := splitOctetCounted(header); ok {
+			if length > srv.conf.MaxMessageSize {
+				return nil, MessageTooLargeError
+			}
+			reader.Discard(hlen)
+			frame := make([]byte, length)
+			_, err := io.ReadFull(reader, frame)
+			return frame, err
+		}
+	}
+	var frame []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if len(frame)+len(chunk) > srv.conf.MaxMessageSize+1 {
+			return nil, MessageTooLargeError
+		}
+		frame = append(frame, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && len(frame) > 0 {
+			return frame, nil
+		}
+		return frame, err
+	}
+}
+
+func (srv *Server) handle(raw []byte) {
+	srv.metrics.received.Add(1)
+	msg, err := Parse(raw)
+	srv.logMu.Lock()
+	defer srv.logMu.Unlock()
+	if err != nil {
+		srv.metrics.parseErrors.Add(1)
+		if srv.conf.KeepUnparsed {
+			if _, err := srv.conf.Logger.Write(raw); err != nil {
+				srv.metrics.logErrors.Add(1)
+			}
+		}
+		return
+	}
+	if err := srv.conf.Logger.Log(msg.Level, msg.Message, msg.Props()...); err != nil {
+		srv.metrics.logErrors.Add(1)
+	}
+}
+
+// extendDeadline resets conn's idle timeout, and reports false if the server is shutting down.
+// It holds the lock so Shutdown's deadline can't be overwritten.
+func (srv *Server) extendDeadline(conn net.Conn) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.closed {
+		return false
+	}
+	if srv.conf.IdleTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(srv.conf.IdleTimeout))
+	}
+	return true
+}
+
+func (srv *Server) isClosed() bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.closed
+}
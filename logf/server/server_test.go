@@ -0,0 +1,300 @@
+// Copyright 2023 appkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/decentplatforms/appkit/logf"
+	"github.com/decentplatforms/appkit/logf/formats"
+)
+
+// ChanWriter sends each write to a channel so tests can wait for relayed messages.
+type ChanWriter chan string
+
+func (writer ChanWriter) Write(msg []byte) (n int, err error) {
+	writer <- string(msg)
+	return len(msg), nil
+}
+
+func (writer ChanWriter) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case msg := <-writer:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for relayed message")
+		return ""
+	}
+}
+
+// testFormat writes the parts of a record the server controls, so tests can compare them exactly.
+func testFormat(level logf.LogLevel, msg string, props logf.PropsView) string {
+	parts := []string{fmt.Sprint(int(level)), msg}
+	for _, prop := range props.Slice() {
+		parts = append(parts, fmt.Sprintf("%s=%v", prop.Name, prop.Value))
+	}
+	return strings.Join(parts, "|")
+}
+
+func testServer(t *testing.T, conf Config) (*Server, ChanWriter) {
+	t.Helper()
+	writer := make(ChanWriter, 16)
+	log, err := logf.NewLogger(logf.Config{
+		MaxLevel:     logf.Debug,
+		DefaultLevel: logf.Notice,
+		Format:       testFormat,
+		Output:       writer,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Logger = log
+	srv, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, writer
+}
+
+var parse_cases = map[string]struct {
+	raw    string
+	wanted Message
+}{
+	"rfc5424": {
+		raw: `<14>1 2023-06-01T12:00:00Z test-host test-app 42 test-log - test log`,
+		wanted: Message{Level: logf.Informational, Facility: 1, Hostname: "test-host", AppName: "test-app",
+			ProcID: "42", Tag: "test-log", Message: "test log"},
+	},
+	"rfc5424.structured": {
+		raw: `<11>1 2023-06-01T12:00:00Z test-host test-app - - [meta detail="a \"quoted\" \]"][x@1 n="1"] msg`,
+		wanted: Message{Level: logf.Error, Facility: 1, Hostname: "test-host", AppName: "test-app",
+			Message: "msg", Structured: []logf.Prop{logf.String("detail", `a "quoted" ]`), logf.String("n", "1")}},
+	},
+	"rfc5424.nilvalues": {
+		raw:    `<14>1 - - - - - -`,
+		wanted: Message{Level: logf.Informational, Facility: 1},
+	},
+	"rfc3164": {
+		raw:    `<14>Jun  1 12:00:00 test-host test-log: test log`,
+		wanted: Message{Level: logf.Informational, Facility: 1, Hostname: "test-host", Tag: "test-log", Message: "test log"},
+	},
+	"rfc3164.pid": {
+		raw:    `<30>2023-06-01T12:00:00Z test-host sshd[42]: accepted`,
+		wanted: Message{Level: logf.Informational, Facility: 3, Hostname: "test-host", Tag: "sshd", ProcID: "42", Message: "accepted"},
+	},
+	"rfc3164.noheader": {
+		raw:    `<13>just a message`,
+		wanted: Message{Level: logf.Notice, Facility: 1, Message: "just a message"},
+	},
+}
+
+func TestParse(t *testing.T) {
+	for name, c := range parse_cases {
+		t.Run(name, func(t *testing.T) {
+			msg, err := Parse([]byte(c.raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg.Timestamp = time.Time{}
+			if fmt.Sprint(msg) != fmt.Sprint(c.wanted) {
+				t.Errorf("wanted %+v, got %+v", c.wanted, msg)
+			}
+		})
+	}
+	for _, raw := range []string{"no pri", "<999>1 - - - - - -", `<14>1 2023-06-01T12:00:00Z host`, `<14>1 - - - - - [unterminated`} {
+		if _, err := Parse([]byte(raw)); err == nil {
+			t.Errorf("expected error parsing %q", raw)
+		}
+	}
+}
+
+func TestRelayHeaders(t *testing.T) {
+	for raw, wanted := range map[string]string{
+		"<3>1 2023-06-01T12:00:00.5Z kernhost - - - - oops": "<3>1 2023-06-01T12:00:00Z kernhost ",
+		"<30>2023-06-01T12:00:00Z test-host sshd[42]: ok":   "<30>1 2023-06-01T12:00:00Z test-host ",
+	} {
+		msg, err := Parse([]byte(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := formats.Syslog5424Format(formats.SyslogConfig{}).FormatAndNormalize(msg.Level, msg.Message, logf.NewProps(msg.Props()...))
+		if !strings.HasPrefix(out, wanted) {
+			t.Errorf("relayed %q as %q, wanted prefix %q", raw, out, wanted)
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	srv, writer := testServer(t, Config{})
+	dir := t.TempDir()
+
+	udp, err := srv.ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcp, err := srv.ListenTCP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unix, err := srv.ListenUnix(filepath.Join(dir, "log.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("udp", func(t *testing.T) {
+		conn, err := net.Dial("udp", udp.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "<14>2023-06-01T12:00:00Z test-host test-log: over udp")
+		wanted := "6|over udp|" + formats.SYSLOG_TIMESTAMP + "=2023-06-01 12:00:00 +0000 UTC|" + formats.SYSLOG_FACILITY + "=1|" +
+			formats.SYSLOG_HOSTNAME + "=test-host|" + formats.SYSLOG_TAG + "=test-log\n"
+		if got := writer.next(t); got != wanted {
+			t.Errorf("wanted %q, got %q", wanted, got)
+		}
+	})
+	t.Run("tcp", func(t *testing.T) {
+		conn, err := net.Dial("tcp", tcp.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		msg := "<11>1 - test-host test-app - - - octet\ncounted"
+		fmt.Fprintf(conn, "<12>1 - test-host test-app - - - newline\n%d %s", len(msg), msg)
+		for _, wanted := range []string{"4|newline|", "3|octet\ncounted|"} {
+			if got := writer.next(t); !strings.HasPrefix(got, wanted) {
+				t.Errorf("wanted prefix %q, got %q", wanted, got)
+			}
+		}
+	})
+	t.Run("unix", func(t *testing.T) {
+		conn, err := net.Dial("unixgram", unix.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "<15>1 - - - - - - over unix")
+		wanted := "7|over unix|" + formats.SYSLOG_FACILITY + "=1\n"
+		if got := writer.next(t); got != wanted {
+			t.Errorf("wanted %q, got %q", wanted, got)
+		}
+	})
+	t.Run("parse errors", func(t *testing.T) {
+		conn, err := net.Dial("tcp", tcp.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "not syslog\n<14>1 - - - - - - after error\n")
+		wanted := "6|after error|" + formats.SYSLOG_FACILITY + "=1\n"
+		if got := writer.next(t); got != wanted {
+			t.Errorf("wanted %q, got %q", wanted, got)
+		}
+		if n := srv.Metrics().ParseErrors; n != 1 {
+			t.Errorf("wanted 1 parse error, got %d", n)
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := net.Dial("tcp", tcp.String()); err == nil {
+		t.Error("expected tcp listener to be closed")
+	}
+	if _, err := srv.ListenUDP("127.0.0.1:0"); err != ServerClosedError {
+		t.Errorf("wanted ServerClosedError, got %v", err)
+	}
+}
+
+func TestServerLimits(t *testing.T) {
+	srv, writer := testServer(t, Config{
+		MaxMessageSize: 32,
+		MaxConnections: 1,
+		KeepUnparsed:   true,
+	})
+	defer srv.Shutdown(context.Background())
+	tcp, err := srv.ListenTCP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("tcp", tcp.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "unparsed\n")
+	if got := writer.next(t); got != "5|unparsed\n" {
+		t.Errorf("wanted unparsed message at default level, got %q", got)
+	}
+
+	second, err := net.Dial("tcp", tcp.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := second.Read(make([]byte, 1)); err == nil {
+		t.Error("expected second connection to be closed")
+	}
+	if n := srv.Metrics().Rejected; n != 1 {
+		t.Errorf("wanted 1 rejected connection, got %d", n)
+	}
+
+	fmt.Fprintf(conn, "<14>1 - - - - - - %s\n", strings.Repeat("x", 64))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("expected oversized message to close the connection")
+	}
+	if n := srv.Metrics().Oversized; n != 1 {
+		t.Errorf("wanted 1 oversized message, got %d", n)
+	}
+}
+
+// failingListener fails every Accept, like a listener out of file descriptors.
+type failingListener struct {
+	net.Listener
+	accepts atomic.Int64
+}
+
+func (listener *failingListener) Accept() (net.Conn, error) {
+	listener.accepts.Add(1)
+	return nil, errors.New("accept: too many open files")
+}
+
+func TestAcceptBackoff(t *testing.T) {
+	srv, _ := testServer(t, Config{})
+	listener := &failingListener{}
+	srv.wg.Add(1)
+	go srv.serveStream(listener)
+	time.Sleep(100 * time.Millisecond)
+	srv.Shutdown(context.Background())
+	// 5, 10, 20, and 40ms delays fit in 100ms; without backoff there'd be millions of accepts.
+	if n := listener.accepts.Load(); n > 10 {
+		t.Errorf("wanted backoff between failed accepts, got %d accepts", n)
+	}
+}